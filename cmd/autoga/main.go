@@ -17,9 +17,89 @@ import (
 func main() {
 	cfg := config.Load()
 
-	fetcher := scraper.NewHTTPFetcher(cfg.FetchTimeout)
-	extractor := scraper.NewReadabilityExtractor()
-	sc := scraper.New(fetcher, extractor, cfg.MaxConcurrency)
+	hostLimits := make(map[string]scraper.HostLimit, len(cfg.HostLimits))
+	for domain, l := range cfg.HostLimits {
+		hostLimits[domain] = scraper.HostLimit(l)
+	}
+	limiter := scraper.NewHostLimiter(scraper.HostLimit(cfg.HostLimit), hostLimits)
+
+	domainPolicies := make(map[string]scraper.DomainPolicy, len(cfg.DomainPolicies))
+	for domain, p := range cfg.DomainPolicies {
+		domainPolicies[domain] = scraper.DomainPolicy(p)
+	}
+	policies := scraper.NewPolicies(domainPolicies)
+
+	var customUnwrappers []scraper.Unwrapper
+	for _, q := range cfg.UnwrapQuery {
+		customUnwrappers = append(customUnwrappers, scraper.NewQueryUnwrapper(q.Domain, q.PathPrefix, q.Params...))
+	}
+	// Unwrapping, robots.txt and archive lookups reach the same hosts as
+	// fetches, so they share the guard.
+	guard := scraper.NewNetGuard(cfg.FetchAllowlist...)
+	client := &http.Client{Timeout: cfg.FetchTimeout, Transport: guard.Transport()}
+	if len(cfg.UnwrapRedirect) > 0 {
+		customUnwrappers = append(customUnwrappers, scraper.NewRedirectUnwrapper(client, cfg.UnwrapRedirect...))
+	}
+	unwrappers := scraper.NewUnwrappers(client, customUnwrappers...)
+
+	fetcherOpts := []scraper.FetcherOption{
+		scraper.WithNetGuard(guard),
+		scraper.WithHostLimiter(limiter),
+		scraper.WithFetchPolicies(policies),
+		scraper.WithFetchUnwrappers(unwrappers),
+		scraper.WithRetry(scraper.RetryPolicy{
+			MaxAttempts: cfg.FetchAttempts,
+			BaseDelay:   cfg.FetchRetryBase,
+			MaxDelay:    cfg.FetchRetryMax,
+		}),
+	}
+	if cfg.RespectRobots {
+		robots := scraper.NewRobotsChecker(client, cfg.RobotsAgent, cfg.RobotsTTL)
+		fetcherOpts = append(fetcherOpts, scraper.WithRobots(robots))
+	}
+	fetcher := scraper.NewHTTPFetcher(cfg.FetchTimeout, fetcherOpts...)
+	var extractorOpts []scraper.ExtractorOption
+	if cfg.SiteRulesFile != "" {
+		rules, err := scraper.NewSiteRules(cfg.SiteRulesFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		extractorOpts = append(extractorOpts, scraper.WithSiteRules(rules))
+	}
+	extractor := scraper.NewCompositeExtractor(extractorOpts...)
+	pool := scraper.NewPool(cfg.MaxConcurrency, cfg.MaxQueue)
+
+	opts := []scraper.Option{
+		scraper.WithUnwrappers(unwrappers),
+		scraper.WithPolicies(policies),
+		scraper.WithAlternates(cfg.AlternateMinScore),
+		scraper.WithPagination(cfg.MaxArticlePages),
+	}
+	if cfg.PlatformHandlers {
+		opts = append(opts, scraper.WithHandlers(scraper.NewHandlers(fetcher)))
+	}
+	if len(cfg.Archives) > 0 {
+		var archives []scraper.Archive
+		for _, a := range cfg.Archives {
+			if a.Wayback {
+				archives = append(archives, scraper.NewWaybackArchive(client, a.URL))
+			} else {
+				archives = append(archives, scraper.NewTemplateArchive(a.URL))
+			}
+		}
+		opts = append(opts, scraper.WithArchives(archives...))
+	}
+	if len(cfg.TrackingParams) > 0 {
+		opts = append(opts, scraper.WithNormalizer(scraper.NewNormalizer(cfg.TrackingParams)))
+	}
+	if cfg.CacheTTL > 0 {
+		cache, err := scraper.NewResultCache(cfg.CacheTTL, cfg.CacheSize, cfg.CacheDir, cfg.CacheDiskSize)
+		if err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+		opts = append(opts, scraper.WithCache(cache))
+	}
+	sc := scraper.New(fetcher, extractor, pool, opts...)
 
 	srv := server.New(cfg, sc)
 
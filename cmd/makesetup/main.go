@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/val/autoga/internal"
 	"github.com/val/autoga/internal/makecom"
 )
 
@@ -25,6 +26,7 @@ type config struct {
 	scenarioName         string
 	rssFeedsFile         string
 	scraperURL           string
+	scraperEscape        string
 	llmAPIURL            string
 	llmModel             string
 	telegramChatID       string
@@ -58,6 +60,7 @@ func main() {
 			Zone:                 cfg.zone,
 			RSSFeedURL:           f.url,
 			ScraperURL:           cfg.scraperURL,
+			ScraperEscape:        cfg.scraperEscape,
 			LLMAPIUrl:            cfg.llmAPIURL,
 			LLMModel:             cfg.llmModel,
 			TelegramChatID:       cfg.telegramChatID,
@@ -115,7 +118,7 @@ func readFeeds(path string) ([]feed, error) {
 }
 
 func loadConfig() config {
-	return config{
+	cfg := config{
 		apiToken:             requireEnv("MAKE_API_TOKEN"),
 		zone:                 getEnv("MAKE_ZONE", "eu1"),
 		teamID:               requireInt("MAKE_TEAM_ID"),
@@ -124,6 +127,7 @@ func loadConfig() config {
 		scenarioName:         getEnv("SCENARIO_NAME", "AutoGA Digest"),
 		rssFeedsFile:         getEnv("RSS_FEEDS", ".rss_feeds.csv"),
 		scraperURL:           requireEnv("AUTOGA_URL"),
+		scraperEscape:        getEnv("AUTOGA_ESCAPE", "sanitize"),
 		llmAPIURL:            requireEnv("LLM_API_URL"),
 		llmModel:             requireEnv("LLM_MODEL"),
 		telegramChatID:       getEnv("TELEGRAM_CHAT_ID", ""),
@@ -134,6 +138,14 @@ func loadConfig() config {
 		keychainScraper:      requireInt("MAKE_KEYCHAIN_SCRAPER"),
 		keychainLLM:          requireInt("MAKE_KEYCHAIN_LLM"),
 	}
+	// Article text is pasted into the LLM request's raw JSON body, which
+	// unescaped quotes and backslashes would break.
+	switch internal.Escape(cfg.scraperEscape) {
+	case internal.EscapeSanitize, internal.EscapeJSONString:
+	default:
+		log.Fatalf("env var AUTOGA_ESCAPE must be sanitize or json_string, got %q", cfg.scraperEscape)
+	}
+	return cfg
 }
 
 func requireEnv(key string) string {
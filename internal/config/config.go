@@ -1,33 +1,123 @@
 package config
 
 import (
+	"encoding/json"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all runtime configuration derived from environment variables.
 type Config struct {
-	Port               string
-	APIKey             string
-	ReadTimeout        time.Duration
-	WriteTimeout       time.Duration
-	FetchTimeout       time.Duration
-	MaxConcurrency     int
-	MaxURLsPerRequest  int
+	Port              string
+	APIKey            string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	FetchTimeout      time.Duration
+	FetchAttempts     int
+	FetchRetryBase    time.Duration
+	FetchRetryMax     time.Duration
+	MaxConcurrency    int
+	MaxQueue          int
+	MaxURLsPerRequest int
+	CacheTTL          time.Duration // zero disables the result cache
+	CacheSize         int
+	CacheDir          string               // on-disk cache location, empty for memory only
+	CacheDiskSize     int                  // max results kept in CacheDir
+	HostLimit         HostLimit            // per-host politeness applied to every host
+	HostLimits        map[string]HostLimit // per-domain overrides of HostLimit
+	UnwrapQuery       []QueryUnwrap        // custom redirectors that carry the target in a query parameter
+	UnwrapRedirect    []string             // custom shortener domains resolved via their redirect
+	TrackingParams    []string             // query parameters stripped from URLs, empty for the built-in list
+	FetchAllowlist    []string             // private hosts, IPs or CIDRs the fetcher may still reach
+	RespectRobots     bool                 // obey robots.txt and Crawl-delay
+	RobotsAgent       string               // product token looked up in robots.txt
+	RobotsTTL         time.Duration        // how long a robots.txt is cached
+	DomainPolicies    map[string]DomainPolicy
+	SiteRulesFile     string  // JSON extraction rules, re-read when changed; empty for none
+	AlternateMinScore float64 // confidence below which AMP and print versions are tried; zero disables
+	MaxArticlePages   int     // pages of a paginated article to merge; 1 disables pagination
+	PlatformHandlers  bool    // read YouTube, Reddit and Telegram links through their structured endpoints
+	Archives          []ArchiveEndpoint
+}
+
+// QueryUnwrap describes a redirector URL pattern: links on Domain whose path
+// starts with PathPrefix point to the URL in the first present Params entry.
+type QueryUnwrap struct {
+	Domain     string
+	PathPrefix string
+	Params     []string
+}
+
+// ArchiveEndpoint is an archive consulted for blocked or paywalled articles.
+// A Wayback endpoint speaks the Wayback Machine availability API; otherwise
+// URL is a snapshot address template containing {url}.
+type ArchiveEndpoint struct {
+	Wayback bool
+	URL     string
+}
+
+// DomainPolicy is the treatment of one domain and its subdomains, read from
+// the DOMAIN_POLICY_FILE.
+type DomainPolicy struct {
+	Skip      bool
+	Allow     bool
+	Reason    string
+	Timeout   time.Duration
+	UserAgent string
+	Headers   map[string]string
+}
+
+// HostLimit caps concurrent requests and spacing between requests to one host.
+type HostLimit struct {
+	MaxConcurrency int
+	MinDelay       time.Duration
 }
 
 // Load reads configuration from environment variables, applying defaults where needed.
 func Load() Config {
-	return Config{
+	cfg := Config{
 		Port:              getEnv("PORT", "8080"),
 		APIKey:            getEnv("API_KEY", ""),
 		ReadTimeout:       getDuration("READ_TIMEOUT", 5*time.Second),
 		WriteTimeout:      getDuration("WRITE_TIMEOUT", 60*time.Second),
 		FetchTimeout:      getDuration("FETCH_TIMEOUT", 15*time.Second),
+		FetchAttempts:     getInt("FETCH_ATTEMPTS", 3),
+		FetchRetryBase:    getDuration("FETCH_RETRY_BASE", 500*time.Millisecond),
+		FetchRetryMax:     getDuration("FETCH_RETRY_MAX", 10*time.Second),
 		MaxConcurrency:    getInt("MAX_CONCURRENCY", 5),
+		MaxQueue:          getInt("MAX_QUEUE", 50),
 		MaxURLsPerRequest: getInt("MAX_URLS_PER_REQUEST", 10),
+		CacheTTL:          getDuration("CACHE_TTL", time.Hour),
+		CacheSize:         getInt("CACHE_SIZE", 512),
+		CacheDir:          getEnv("CACHE_DIR", ""),
+		CacheDiskSize:     getInt("CACHE_DISK_SIZE", 10000),
+		HostLimit: HostLimit{
+			MaxConcurrency: getInt("HOST_MAX_CONCURRENCY", 2),
+			MinDelay:       getDuration("HOST_MIN_DELAY", time.Second),
+		},
+		HostLimits:        getHostLimits("HOST_LIMITS"),
+		UnwrapQuery:       getQueryUnwraps("UNWRAP_QUERY"),
+		UnwrapRedirect:    getList("UNWRAP_REDIRECT"),
+		TrackingParams:    getList("TRACKING_PARAMS"),
+		FetchAllowlist:    getList("FETCH_ALLOWLIST"),
+		RespectRobots:     getBool("RESPECT_ROBOTS", false),
+		RobotsAgent:       getEnv("ROBOTS_AGENT", "autoga"),
+		RobotsTTL:         getDuration("ROBOTS_TTL", 24*time.Hour),
+		DomainPolicies:    loadDomainPolicies(os.Getenv("DOMAIN_POLICY_FILE")),
+		SiteRulesFile:     getEnv("SITE_RULES_FILE", ""),
+		AlternateMinScore: getFloat("ALTERNATE_MIN_CONFIDENCE", 0),
+		Archives:          getArchives("ARCHIVE_ENDPOINTS"),
+		MaxArticlePages:   getInt("MAX_ARTICLE_PAGES", 1),
+		PlatformHandlers:  getBool("PLATFORM_HANDLERS", true),
+	}
+	// A request the queue can never hold would be turned away on every retry.
+	if cfg.MaxQueue > 0 && cfg.MaxQueue < cfg.MaxURLsPerRequest {
+		log.Fatalf("config: MAX_QUEUE (%d) must be at least MAX_URLS_PER_REQUEST (%d)", cfg.MaxQueue, cfg.MaxURLsPerRequest)
 	}
+	return cfg
 }
 
 func getEnv(key, fallback string) string {
@@ -60,3 +150,154 @@ func getInt(key string, fallback int) int {
 	}
 	return n
 }
+
+func getFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fallback
+	}
+	return f
+}
+
+func getBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return b
+}
+
+// getHostLimits parses a comma-separated list of domain=concurrency/delay
+// entries, e.g. "example.com=1/3s,news.example.org=4/0s". Malformed entries
+// are logged and skipped.
+func getHostLimits(key string) map[string]HostLimit {
+	limits := make(map[string]HostLimit)
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		domain, spec, ok := strings.Cut(entry, "=")
+		conc, delay, ok2 := strings.Cut(spec, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(conc))
+		d, err2 := time.ParseDuration(strings.TrimSpace(delay))
+		if !ok || !ok2 || err != nil || err2 != nil || strings.TrimSpace(domain) == "" {
+			log.Printf("config: ignoring malformed %s entry %q", key, entry)
+			continue
+		}
+		limits[strings.TrimSpace(domain)] = HostLimit{MaxConcurrency: n, MinDelay: d}
+	}
+	return limits
+}
+
+// getQueryUnwraps parses a comma-separated list of domain[/path]=param[|param]
+// entries, e.g. "click.example.com/r=url|u,news.example.org/out=to".
+// Malformed entries are logged and skipped.
+func getQueryUnwraps(key string) []QueryUnwrap {
+	var out []QueryUnwrap
+	for _, entry := range getList(key) {
+		pattern, params, ok := strings.Cut(entry, "=")
+		domain, path, _ := strings.Cut(strings.TrimSpace(pattern), "/")
+		q := QueryUnwrap{Domain: domain, PathPrefix: "/" + path}
+		for _, p := range strings.Split(params, "|") {
+			if p = strings.TrimSpace(p); p != "" {
+				q.Params = append(q.Params, p)
+			}
+		}
+		if !ok || domain == "" || len(q.Params) == 0 {
+			log.Printf("config: ignoring malformed %s entry %q", key, entry)
+			continue
+		}
+		out = append(out, q)
+	}
+	return out
+}
+
+// getArchives parses a comma-separated list of archive endpoints: "wayback"
+// for the public Wayback Machine, "wayback=<availability API URL>" for a
+// compatible service, or a snapshot URL template containing {url}.
+// Malformed entries are logged and skipped.
+func getArchives(key string) []ArchiveEndpoint {
+	var out []ArchiveEndpoint
+	for _, entry := range getList(key) {
+		api, isAPI := strings.CutPrefix(entry, "wayback=")
+		switch {
+		case entry == "wayback":
+			out = append(out, ArchiveEndpoint{Wayback: true})
+		case isAPI && api != "":
+			out = append(out, ArchiveEndpoint{Wayback: true, URL: api})
+		case strings.Contains(entry, "{url}"):
+			out = append(out, ArchiveEndpoint{URL: entry})
+		default:
+			log.Printf("config: ignoring malformed %s entry %q", key, entry)
+		}
+	}
+	return out
+}
+
+// getList splits a comma-separated variable, dropping empty items.
+func getList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// loadDomainPolicies reads a JSON object mapping domains to policies, e.g.
+//
+//	{
+//	  "facebook.com": {"skip": true, "reason": "social network"},
+//	  "example.com": {"timeout": "30s", "user_agent": "autoga/1.0", "headers": {"Cookie": "consent=1"}}
+//	}
+//
+// An empty path means no policies. A file that cannot be read or parsed is
+// fatal, since silently dropping a blocklist is worse than not starting.
+func loadDomainPolicies(path string) map[string]DomainPolicy {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("config: read DOMAIN_POLICY_FILE: %v", err)
+	}
+	var entries map[string]struct {
+		Skip      bool              `json:"skip"`
+		Allow     bool              `json:"allow"`
+		Reason    string            `json:"reason"`
+		Timeout   string            `json:"timeout"`
+		UserAgent string            `json:"user_agent"`
+		Headers   map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Fatalf("config: parse DOMAIN_POLICY_FILE %s: %v", path, err)
+	}
+
+	policies := make(map[string]DomainPolicy, len(entries))
+	for domain, e := range entries {
+		p := DomainPolicy{
+			Skip:      e.Skip,
+			Allow:     e.Allow,
+			Reason:    e.Reason,
+			UserAgent: e.UserAgent,
+			Headers:   e.Headers,
+		}
+		if e.Timeout != "" {
+			if p.Timeout, err = time.ParseDuration(e.Timeout); err != nil {
+				log.Fatalf("config: DOMAIN_POLICY_FILE %s: timeout of %q: %v", path, domain, err)
+			}
+		}
+		policies[strings.TrimSpace(domain)] = p
+	}
+	return policies
+}
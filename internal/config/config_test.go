@@ -0,0 +1,41 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetQueryUnwraps(t *testing.T) {
+	tests := []struct {
+		env  string
+		want []QueryUnwrap
+	}{
+		{"", nil},
+		{"news.example.com=url", []QueryUnwrap{{Domain: "news.example.com", PathPrefix: "/", Params: []string{"url"}}}},
+		{
+			" news.example.com/out/ = to | dest , mail.example.org/r=u",
+			[]QueryUnwrap{
+				{Domain: "news.example.com", PathPrefix: "/out/", Params: []string{"to", "dest"}},
+				{Domain: "mail.example.org", PathPrefix: "/r", Params: []string{"u"}},
+			},
+		},
+		{"example.com=a||b|", []QueryUnwrap{{Domain: "example.com", PathPrefix: "/", Params: []string{"a", "b"}}}},
+		// Malformed entries are skipped and the rest kept.
+		{"example.com,=url,example.org=,example.org= | ,example.net=u", []QueryUnwrap{{Domain: "example.net", PathPrefix: "/", Params: []string{"u"}}}},
+		{"/path=url", nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("UNWRAP_QUERY", tt.env)
+		if got := getQueryUnwraps("UNWRAP_QUERY"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UNWRAP_QUERY=%q: got %+v, want %+v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestGetList(t *testing.T) {
+	t.Setenv("TRACKING_PARAMS", " utm_* ,, fbclid,mc_*,")
+	want := []string{"utm_*", "fbclid", "mc_*"}
+	if got := getList("TRACKING_PARAMS"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
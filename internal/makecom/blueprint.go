@@ -17,6 +17,10 @@ type ScenarioConfig struct {
 	TelegramConnectionID int
 	UpstashURL           string
 	UpstashTTLSec        int
+	// ScraperEscape is the autoga escape mode requested for article text:
+	// "sanitize" (default) or "json_string". Text is pasted into the LLM
+	// request's raw JSON body, so "raw" is only safe with other templates.
+	ScraperEscape string
 	// Make.com keychain IDs — set up once in Make.com UI (Connections).
 	// Auth is injected automatically at runtime; no API keys embedded in blueprint.
 	KeychainUpstash int // Upstash REST API key
@@ -41,9 +45,9 @@ func BuildBlueprint(cfg ScenarioConfig) Blueprint {
 		Flow: []Module{
 			rssModule(cfg.RSSFeedURL),
 			upstashCheckModule(cfg.UpstashURL, cfg.KeychainUpstash),
-			scraperModule(cfg.ScraperURL, cfg.KeychainScraper),
+			scraperModule(cfg.ScraperURL, cfg.KeychainScraper, cfg.ScraperEscape),
 			llmModule(cfg.LLMAPIUrl, cfg.KeychainLLM, cfg.LLMModel),
-			telegramModule(cfg.TelegramChatID, cfg.TelegramConnectionID),
+			telegramModule(cfg.TelegramChatID, cfg.TelegramConnectionID, cfg.ScraperEscape),
 			upstashSetModule(cfg.UpstashURL, cfg.KeychainUpstash, cfg.UpstashTTLSec),
 		},
 		Metadata: BlueprintMetadata{
@@ -177,10 +181,14 @@ func upstashSetModule(upstashURL string, keychainID int, ttlSec int) Module {
 	}
 }
 
-func scraperModule(scraperURL string, keychainID int) Module {
-	body := mustJSON(map[string]any{
+func scraperModule(scraperURL string, keychainID int, escape string) Module {
+	req := map[string]any{
 		"urls": []string{"{{1.url}}"},
-	})
+	}
+	if escape != "" {
+		req["escape"] = escape
+	}
+	body := mustJSON(req)
 	return Module{
 		ID:      3,
 		Module:  "http:MakeRequest",
@@ -238,8 +246,9 @@ func scraperModule(scraperURL string, keychainID int) Module {
 
 func llmModule(apiURL string, keychainID int, model string) Module {
 	// Module 3 (scraper) returns results[1] (1-indexed per Make.com convention).
-	// Article content is normalized (no newlines) by the scraper, so embedding
-	// it in a JSON string via rawBodyContent is safe.
+	// Title and content are escaped by the scraper according to
+	// ScenarioConfig.ScraperEscape, so embedding them in a JSON string via
+	// rawBodyContent is safe.
 	const prompt = "Напиши короткий дайджест цієї статті українською мовою (3-5 речень)." +
 		"\n\nЗаголовок: {{3.data.results[1].title}}" +
 		"\n\nТекст: {{3.data.results[1].content}}"
@@ -307,11 +316,18 @@ func llmModule(apiURL string, keychainID int, model string) Module {
 	}
 }
 
-func telegramModule(chatID string, connectionID int) Module {
-	// Module 3 (scraper) and module 4 (LLM/Ollama).
-	const text = "{{3.data.results[1].title}}" +
+func telegramModule(chatID string, connectionID int, escape string) Module {
+	// Module 3 (scraper) and module 4 (LLM/Ollama). The source link prefers
+	// the canonical URL so redirect and tracking wrappers are not published.
+	// A json_string title carries backslash escapes meant for the LLM
+	// request body, so the message takes the RSS item's title instead.
+	title := "{{3.data.results[1].title}}"
+	if escape == "json_string" {
+		title = "{{ifempty(1.title; 3.data.results[1].title)}}"
+	}
+	text := title +
 		"\n\n{{4.data.message.content}}" +
-		"\n\nДжерело: {{3.data.results[1].url}}"
+		"\n\nДжерело: {{ifempty(3.data.results[1].canonical_url; 3.data.results[1].url)}}"
 
 	m := Module{
 		ID:      5,
@@ -0,0 +1,145 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+
+	"github.com/val/autoga/internal"
+)
+
+// Variants reported in ArticleResult.Variant.
+const (
+	variantAMP       = "amp"        // <link rel="amphtml">
+	variantPrint     = "print"      // <link rel="alternate" media="print">
+	variantOutputAMP = "output_amp" // the page's own URL with ?output=amp
+)
+
+// alternate is another version of a page that may extract more cleanly.
+type alternate struct {
+	variant string
+	url     string
+}
+
+// WithAlternates makes the Scraper try a page's cleaner versions when the
+// page itself is blocked, walled or yields no text, or when its Confidence
+// is below minConfidence. The advertised AMP page comes first, then the
+// print version. The unadvertised ?output=amp variant is only guessed for
+// pages that failed or were walled, as most sites do not serve it. Zero
+// disables it.
+func WithAlternates(minConfidence float64) Option {
+	return func(s *Scraper) { s.minConfidence = minConfidence }
+}
+
+// needsAlternate reports whether r is poor enough to try alternates.
+func (s *Scraper) needsAlternate(r internal.ArticleResult) bool {
+	// Documents such as PDFs have no alternates.
+	if s.minConfidence <= 0 || r.Extractor == extractorPDF || r.Extractor == extractorPlainText {
+		return false
+	}
+	switch r.ErrorCode {
+	case "":
+		return r.Wall != "" || r.Confidence < s.minConfidence
+	case internal.ErrExtractionEmpty, internal.ErrBlocked, internal.ErrPaywall:
+		return true
+	}
+	return false
+}
+
+// tryAlternates fetches the alternates of clean in order until one reaches
+// the threshold and returns the best result, primary included. body is the
+// primary page's HTML, nil if it could not be fetched.
+func (s *Scraper) tryAlternates(ctx context.Context, primary internal.ArticleResult, body []byte, clean, snippet string, format internal.Format) internal.ArticleResult {
+	base, err := url.Parse(firstNonEmpty(primary.FinalURL, clean))
+	if err != nil {
+		return primary
+	}
+	best := primary
+	attempts, waitMS := primary.Attempts, primary.LimiterWaitMS
+	guess := primary.Error != "" || primary.Wall != ""
+	for _, alt := range findAlternates(body, base, clean, guess) {
+		if ctx.Err() != nil {
+			break
+		}
+		if u, err := url.Parse(alt.url); err != nil || s.policies.skipReason(u.Hostname()) != "" {
+			continue
+		}
+		r, _ := s.fetchExtract(ctx, alt.url, snippet, format)
+		attempts += r.Attempts
+		waitMS += r.LimiterWaitMS
+		if !better(r, best) {
+			continue
+		}
+		// An AMP or print page declares the article as its canonical address.
+		r.URL = clean
+		r.CanonicalURL = firstNonEmpty(primary.CanonicalURL, r.CanonicalURL)
+		r.Variant = alt.variant
+		r.VariantURL = alt.url
+		best = r
+		if best.Wall == "" && best.Confidence >= s.minConfidence {
+			break
+		}
+	}
+	best.Attempts, best.LimiterWaitMS = attempts, waitMS
+	return best
+}
+
+// better reports whether r should replace best: a success beats a
+// failure, an article beats a walled page, then confidence decides.
+func better(r, best internal.ArticleResult) bool {
+	switch {
+	case r.Error != "":
+		return false
+	case best.Error != "":
+		return true
+	case (r.Wall == "") != (best.Wall == ""):
+		return r.Wall == ""
+	}
+	return r.Confidence > best.Confidence
+}
+
+// findAlternates lists the versions of a page advertised in body, followed
+// by the ?output=amp guess if guess is set. Addresses equal to clean are
+// left out.
+func findAlternates(body []byte, base *url.URL, clean string, guess bool) []alternate {
+	var alts []alternate
+	seen := map[string]bool{clean: true}
+	add := func(variant, href string) {
+		if abs := resolveURL(base, strings.TrimSpace(href)); abs != "" && !seen[abs] {
+			seen[abs] = true
+			alts = append(alts, alternate{variant: variant, url: abs})
+		}
+	}
+
+	if body != nil {
+		if doc, err := html.Parse(bytes.NewReader(body)); err == nil {
+			var printHrefs []string
+			for _, n := range dom.GetElementsByTagName(doc, "link") {
+				rel := strings.Fields(strings.ToLower(dom.GetAttribute(n, "rel")))
+				switch {
+				case slices.Contains(rel, "amphtml"):
+					add(variantAMP, dom.GetAttribute(n, "href"))
+				case slices.Contains(rel, "alternate") && strings.EqualFold(strings.TrimSpace(dom.GetAttribute(n, "media")), "print"):
+					printHrefs = append(printHrefs, dom.GetAttribute(n, "href"))
+				}
+			}
+			for _, href := range printHrefs {
+				add(variantPrint, href)
+			}
+		}
+	}
+
+	if !guess {
+		return alts
+	}
+	if u, err := url.Parse(clean); err == nil && u.Query().Get("output") == "" {
+		u.RawQuery = strings.TrimPrefix(u.RawQuery+"&output=amp", "&")
+		add(variantOutputAMP, u.String())
+	}
+	return alts
+}
@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+// scoredExtractor is textExtractor with a confidence growing with the word
+// count, reaching 1 at 20 words, and a paywall flag for subscription prompts.
+type scoredExtractor struct{}
+
+func (scoredExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	r, err := textExtractor{}.Extract(doc)
+	r.Confidence = min(float64(len(strings.Fields(r.Content)))/20, 1)
+	if strings.Contains(r.Content, "Subscribe to continue") {
+		r.Wall = wallPaywall
+	}
+	return r, err
+}
+
+func TestFindAlternates(t *testing.T) {
+	body := []byte(`<head>
+		<link rel="alternate" media="print" href="/print/a">
+		<link rel="AmpHTML" href="https://amp.example.com/a">
+		<link rel="alternate" hreflang="de" href="/de/a">
+		<link rel="amphtml" href="https://example.com/a">
+	</head>`)
+	base := mustParse(t, "https://example.com/a")
+
+	got := findAlternates(body, base, "https://example.com/a", false)
+	want := []alternate{{variantAMP, "https://amp.example.com/a"}, {variantPrint, "https://example.com/print/a"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	got = findAlternates(body, base, "https://example.com/a", true)
+	want = append(want, alternate{variantOutputAMP, "https://example.com/a?output=amp"})
+	if !slices.Equal(got, want) {
+		t.Errorf("with the guess got %v, want %v", got, want)
+	}
+	if got := findAlternates(nil, base, "https://example.com/a?output=1", true); len(got) != 0 {
+		t.Errorf("a URL with its own output parameter got %v", got)
+	}
+}
+
+func TestNeedsAlternate(t *testing.T) {
+	s := &Scraper{minConfidence: 0.5}
+	tests := []struct {
+		name string
+		r    internal.ArticleResult
+		want bool
+	}{
+		{"confident", internal.ArticleResult{Confidence: 0.8}, false},
+		{"low confidence", internal.ArticleResult{Confidence: 0.3}, true},
+		{"walled", internal.ArticleResult{Confidence: 0.9, Wall: wallPaywall}, true},
+		{"empty", internal.ArticleResult{ErrorCode: internal.ErrExtractionEmpty}, true},
+		{"blocked", internal.ArticleResult{ErrorCode: internal.ErrBlocked}, true},
+		{"not found", internal.ArticleResult{ErrorCode: internal.ErrHTTP4xx}, false},
+		{"PDF", internal.ArticleResult{Extractor: extractorPDF}, false},
+	}
+	for _, tt := range tests {
+		if got := s.needsAlternate(tt.r); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if (&Scraper{}).needsAlternate(internal.ArticleResult{ErrorCode: internal.ErrBlocked}) {
+		t.Errorf("alternates tried with a zero threshold")
+	}
+}
+
+func TestScrapeAlternates(t *testing.T) {
+	article := strings.Repeat("word ", 30)
+	tests := []struct {
+		name        string
+		page        string
+		status      int
+		wantVariant string
+		wantPaths   []string
+	}{
+		{
+			"low confidence takes the AMP page",
+			`<link rel="amphtml" href="/amp"><p>Short teaser.</p>`, http.StatusOK,
+			variantAMP, []string{"/a", "/amp"},
+		},
+		{
+			"low confidence without advertised versions makes no guess",
+			`<p>Short teaser.</p>`, http.StatusOK,
+			"", []string{"/a"},
+		},
+		{
+			"walled page falls through to the output=amp guess",
+			`<link rel="alternate" media="print" href="/print"><p>Teaser. Subscribe to continue</p>`, http.StatusOK,
+			variantOutputAMP, []string{"/a", "/print", "/a"},
+		},
+		{
+			"blocked page guesses output=amp",
+			`denied`, http.StatusForbidden,
+			variantOutputAMP, []string{"/a", "/a"},
+		},
+	}
+	for _, tt := range tests {
+		srv := newStubPlatform(t, map[string]http.HandlerFunc{
+			"/a": func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("output") == "amp" {
+					serveBody("text/html", "<p>"+article+"</p>")(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.page)
+			},
+			"/amp":   serveBody("text/html", "<p>"+article+"</p>"),
+			"/print": serveBody("text/html", "<p>Print teaser. Subscribe to continue</p>"),
+		})
+		s := New(stubFetcher(), scoredExtractor{}, NewPool(1, 10), WithAlternates(0.5))
+		rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{srv.URL + "/a"}})
+		if err != nil {
+			t.Fatalf("%s: Scrape: %v", tt.name, err)
+		}
+		r := rs[0]
+		if r.Variant != tt.wantVariant || r.URL != srv.URL+"/a" {
+			t.Errorf("%s: got variant %q, url %q, error %q", tt.name, r.Variant, r.URL, r.Error)
+		}
+		if tt.wantVariant != "" && (r.Content != strings.TrimSpace(article) || r.Attempts != len(tt.wantPaths)) {
+			t.Errorf("%s: got content %q after %d attempts", tt.name, r.Content, r.Attempts)
+		}
+		var paths []string
+		for _, req := range srv.recorded() {
+			paths = append(paths, req.URL.Path)
+		}
+		if !slices.Equal(paths, tt.wantPaths) {
+			t.Errorf("%s: requested %q, want %q", tt.name, paths, tt.wantPaths)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/val/autoga/internal"
+	"github.com/val/autoga/internal/useragent"
+)
+
+// DefaultWaybackEndpoint is the Wayback Machine availability API.
+const DefaultWaybackEndpoint = "https://archive.org/wayback/available"
+
+// maxArchiveResponse caps the size of an archive lookup response.
+const maxArchiveResponse = 1 << 20
+
+// sourceArchive is ArticleResult.Source for content from a snapshot.
+const sourceArchive = "archive"
+
+// Archive finds a stored snapshot of an article.
+type Archive interface {
+	// Snapshot returns the address of a snapshot of rawURL, or "" if the
+	// archive has none.
+	Snapshot(ctx context.Context, rawURL string) (string, error)
+}
+
+// WithArchives makes the Scraper fetch the article from the first archive
+// with a usable snapshot when the site answers 401, 402, 403 or 451, serves
+// a bot challenge, or the article is paywalled. Archives are consulted in
+// order, after any alternates.
+func WithArchives(archives ...Archive) Option {
+	return func(s *Scraper) { s.archives = archives }
+}
+
+// needsArchive reports whether r should be replaced by a snapshot.
+func needsArchive(r internal.ArticleResult) bool {
+	switch r.ErrorCode {
+	case internal.ErrBlocked, internal.ErrPaywall:
+		return true
+	case "":
+		return r.Wall == wallPaywall
+	}
+	return false
+}
+
+// tryArchives fetches snapshots of clean until one yields an article
+// without a wall and returns the best result, primary included.
+func (s *Scraper) tryArchives(ctx context.Context, primary internal.ArticleResult, clean, snippet string, format internal.Format) internal.ArticleResult {
+	best := primary
+	attempts, waitMS := primary.Attempts, primary.LimiterWaitMS
+	for _, a := range s.archives {
+		if ctx.Err() != nil {
+			break
+		}
+		snap, err := a.Snapshot(ctx, clean)
+		if err != nil || snap == "" {
+			continue
+		}
+		if u, err := url.Parse(snap); err != nil || s.policies.skipReason(u.Hostname()) != "" {
+			continue
+		}
+		r, _ := s.fetchDocument(ctx, snap, Document{Snippet: snippet, Format: format, Snapshot: true})
+		attempts += r.Attempts
+		waitMS += r.LimiterWaitMS
+		if !better(r, best) {
+			continue
+		}
+		r.URL = clean
+		r.CanonicalURL = firstNonEmpty(primary.CanonicalURL, r.CanonicalURL)
+		r.Variant, r.VariantURL = "", ""
+		r.Source = sourceArchive
+		r.SnapshotURL = snap
+		best = r
+		if best.Wall == "" {
+			break
+		}
+	}
+	best.Attempts, best.LimiterWaitMS = attempts, waitMS
+	return best
+}
+
+// WaybackArchive looks snapshots up with the Wayback Machine availability
+// API, or a service that answers in the same format.
+type WaybackArchive struct {
+	client   *http.Client
+	endpoint string
+}
+
+// NewWaybackArchive creates a WaybackArchive querying endpoint over client.
+// An empty endpoint means DefaultWaybackEndpoint.
+func NewWaybackArchive(client *http.Client, endpoint string) *WaybackArchive {
+	if endpoint == "" {
+		endpoint = DefaultWaybackEndpoint
+	}
+	return &WaybackArchive{client: client, endpoint: endpoint}
+}
+
+// waybackTimestampRe finds the timestamp segment of a Wayback snapshot URL.
+var waybackTimestampRe = regexp.MustCompile(`/web/(\d{1,14})/`)
+
+// Snapshot returns the closest available snapshot. The address asks for
+// the page as archived, without the Wayback toolbar.
+func (w *WaybackArchive) Snapshot(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(w.endpoint)
+	if err != nil {
+		return "", fmt.Errorf("wayback endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("url", rawURL)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", useragent.Next())
+	req.Header.Set("Accept", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		drain(resp.Body)
+		return "", fmt.Errorf("wayback lookup: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		ArchivedSnapshots struct {
+			Closest struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxArchiveResponse)).Decode(&body); err != nil {
+		return "", fmt.Errorf("wayback lookup: %w", err)
+	}
+	c := body.ArchivedSnapshots.Closest
+	if !c.Available || c.URL == "" || (c.Status != "" && c.Status != "200") {
+		return "", nil
+	}
+	snap := c.URL
+	if rest, ok := strings.CutPrefix(snap, "http://web.archive.org/"); ok {
+		snap = "https://web.archive.org/" + rest
+	}
+	if loc := waybackTimestampRe.FindStringSubmatchIndex(snap); loc != nil {
+		snap = snap[:loc[3]] + "id_" + snap[loc[3]:]
+	}
+	return snap, nil
+}
+
+// TemplateArchive addresses snapshots directly by substituting the article
+// URL into a template, e.g. "https://archive.example.org/newest/{url}".
+type TemplateArchive struct {
+	template string
+}
+
+// NewTemplateArchive creates a TemplateArchive. template must contain {url}.
+func NewTemplateArchive(template string) *TemplateArchive {
+	return &TemplateArchive{template: template}
+}
+
+// Snapshot returns the template with rawURL substituted. Whether a
+// snapshot exists is only known once it is fetched.
+func (t *TemplateArchive) Snapshot(_ context.Context, rawURL string) (string, error) {
+	return strings.ReplaceAll(t.template, "{url}", rawURL), nil
+}
@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestWaybackArchiveSnapshot(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/wayback/available": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("url") == "https://example.com/missing" {
+				serveBody("application/json", `{"url": "https://example.com/missing", "archived_snapshots": {}}`)(w, r)
+				return
+			}
+			serveBody("application/json", `{"archived_snapshots": {"closest": {"status": "200", "available": true,
+				"url": "http://web.archive.org/web/20240102030405/https://example.com/a", "timestamp": "20240102030405"}}}`)(w, r)
+		},
+	})
+	a := NewWaybackArchive(srv.Client(), srv.URL+"/wayback/available")
+
+	snap, err := a.Snapshot(context.Background(), "https://example.com/a")
+	if want := "https://web.archive.org/web/20240102030405id_/https://example.com/a"; err != nil || snap != want {
+		t.Errorf("got %q, %v, want %q", snap, err, want)
+	}
+	snap, err = a.Snapshot(context.Background(), "https://example.com/missing")
+	if err != nil || snap != "" {
+		t.Errorf("got %q, %v, want no snapshot", snap, err)
+	}
+	if reqs := srv.recorded(); len(reqs) != 2 || reqs[0].URL.Query().Get("url") != "https://example.com/a" {
+		t.Errorf("lookups did not pass the article URL")
+	}
+}
+
+func TestTemplateArchiveSnapshot(t *testing.T) {
+	a := NewTemplateArchive("https://archive.example.org/newest/{url}")
+	snap, err := a.Snapshot(context.Background(), "https://example.com/a?b=c")
+	if want := "https://archive.example.org/newest/https://example.com/a?b=c"; err != nil || snap != want {
+		t.Errorf("got %q, %v, want %q", snap, err, want)
+	}
+}
+
+func TestScrapeArchiveFallback(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/article": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		},
+		"/wayback": serveBody("application/json", `{"archived_snapshots": {}}`),
+		"/second":  serveBody("text/html", "<p>Archived article text.</p>"),
+		"/third":   serveBody("text/html", "<p>Never reached.</p>"),
+	})
+	fetcher := stubFetcher()
+	s := newTestScraper(t, fetcher.Fetch, WithArchives(
+		NewWaybackArchive(srv.Client(), srv.URL+"/wayback"),
+		NewTemplateArchive(srv.URL+"/first?url={url}"), // 404s
+		NewTemplateArchive(srv.URL+"/second?url={url}"),
+		NewTemplateArchive(srv.URL+"/third?url={url}"),
+	))
+
+	target := srv.URL + "/article"
+	rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{target}})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	r := rs[0]
+	if r.Error != "" || r.Content != "Archived article text." {
+		t.Fatalf("got content %q, error %q", r.Content, r.Error)
+	}
+	if r.Source != sourceArchive || r.SnapshotURL != srv.URL+"/second?url="+target || r.URL != target {
+		t.Errorf("got source %q, snapshot %q, url %q", r.Source, r.SnapshotURL, r.URL)
+	}
+
+	// Archives are tried in order, stopping at the first usable snapshot.
+	var paths []string
+	for _, req := range srv.recorded() {
+		paths = append(paths, req.URL.Path)
+	}
+	if want := []string{"/article", "/wayback", "/first", "/second"}; !slices.Equal(paths, want) {
+		t.Errorf("requested %q, want %q", paths, want)
+	}
+}
+
+func TestArchiveGuardedHosts(t *testing.T) {
+	site := newServerOn(t, "127.0.0.2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable for legal reasons", http.StatusUnavailableForLegalReasons)
+	}))
+	archive := newServerOn(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wayback" {
+			serveBody("application/json", `{"archived_snapshots": {}}`)(w, r)
+			return
+		}
+		serveBody("text/html", "<p>Private snapshot.</p>")(w, r)
+	}))
+
+	for _, tt := range []struct {
+		name  string
+		allow []string
+		want  string
+	}{
+		{"archive host not allowed", []string{"127.0.0.2"}, ""},
+		{"archive host allowed", []string{"127.0.0.2", "127.0.0.1"}, "Private snapshot."},
+	} {
+		guard := NewNetGuard(tt.allow...)
+		fetcher := NewHTTPFetcher(5*time.Second, WithNetGuard(guard))
+		s := newTestScraper(t, fetcher.Fetch, WithArchives(NewTemplateArchive(archive.URL+"/{url}")))
+		rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{site.URL + "/a"}})
+		if err != nil {
+			t.Fatalf("%s: Scrape: %v", tt.name, err)
+		}
+		if rs[0].Content != tt.want {
+			t.Errorf("%s: got content %q, error %q, want %q", tt.name, rs[0].Content, rs[0].Error, tt.want)
+		}
+
+		// The availability lookup goes through the same guard.
+		client := &http.Client{Transport: guard.Transport()}
+		_, err = NewWaybackArchive(client, archive.URL+"/wayback").Snapshot(context.Background(), site.URL+"/a")
+		if allowed := tt.want != ""; (err == nil) != allowed {
+			t.Errorf("%s: wayback lookup got %v", tt.name, err)
+		} else if !allowed && classify(err).Code != internal.ErrForbiddenTarget {
+			t.Errorf("%s: wayback lookup got %v, want %s", tt.name, err, internal.ErrForbiddenTarget)
+		}
+	}
+}
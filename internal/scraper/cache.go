@@ -0,0 +1,298 @@
+package scraper
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/val/autoga/internal"
+)
+
+// Cache stores successful results keyed by article URL.
+type Cache interface {
+	Get(key string) (internal.ArticleResult, bool)
+	Set(key string, r internal.ArticleResult)
+}
+
+// staleTempAge is how old a temp file must be before pruning removes it;
+// younger ones may belong to a write in progress.
+const staleTempAge = time.Minute
+
+// ResultCache is an in-memory LRU with a TTL, optionally backed by a
+// directory of JSON files so entries survive restarts. Disk access is
+// best-effort: I/O errors degrade to cache misses.
+type ResultCache struct {
+	ttl      time.Duration
+	size     int
+	dir      string
+	diskSize int
+
+	mu    sync.Mutex
+	ll    *list.List // front = most recently used
+	items map[string]*list.Element
+
+	diskMu    sync.Mutex
+	diskFiles int // entries on disk, counted at the last prune and since added
+}
+
+type cacheEntry struct {
+	Key     string                 `json:"key"`
+	Result  internal.ArticleResult `json:"result"`
+	Expires time.Time              `json:"expires"`
+}
+
+// NewResultCache creates a cache holding up to size entries in memory for
+// ttl. If dir is non-empty it is created if needed, expired files in it
+// are removed, and entries are persisted there as well, up to diskSize
+// files: when a write goes past that, expired files and then the oldest
+// are removed.
+func NewResultCache(ttl time.Duration, size int, dir string, diskSize int) (*ResultCache, error) {
+	c := &ResultCache{
+		ttl:      ttl,
+		size:     max(size, 1),
+		dir:      dir,
+		diskSize: max(diskSize, 1),
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create cache dir: %w", err)
+		}
+		c.diskMu.Lock()
+		c.pruneDisk()
+		c.diskMu.Unlock()
+	}
+	return c, nil
+}
+
+// Get returns the cached result for key if present and not expired.
+func (c *ResultCache) Get(key string) (internal.ArticleResult, bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if now.Before(e.Expires) {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			return e.Result, true
+		}
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return internal.ArticleResult{}, false
+	}
+	e, ok := c.readDisk(key)
+	if !ok || e.Key != key {
+		return internal.ArticleResult{}, false
+	}
+	if !now.Before(e.Expires) {
+		_ = os.Remove(c.path(key))
+		return internal.ArticleResult{}, false
+	}
+	c.mu.Lock()
+	c.insert(e)
+	c.mu.Unlock()
+	return e.Result, true
+}
+
+// Set stores r under key for the cache TTL.
+func (c *ResultCache) Set(key string, r internal.ArticleResult) {
+	e := &cacheEntry{Key: key, Result: r, Expires: time.Now().Add(c.ttl)}
+
+	c.mu.Lock()
+	c.insert(e)
+	c.mu.Unlock()
+
+	if c.dir != "" {
+		c.writeDisk(e)
+	}
+}
+
+// insert adds or replaces e and evicts the least recently used entries. Must hold c.mu.
+func (c *ResultCache) insert(e *cacheEntry) {
+	if el, ok := c.items[e.Key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[e.Key] = c.ll.PushFront(e)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).Key)
+	}
+}
+
+func (c *ResultCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *ResultCache) readDisk(key string) (*cacheEntry, bool) {
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	return &e, true
+}
+
+// writeDisk persists e via a temp file and rename so readers never see a
+// partial file, pruning the directory once it holds more than diskSize entries.
+func (c *ResultCache) writeDisk(e *cacheEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(b)
+	cerr := tmp.Close()
+
+	c.diskMu.Lock()
+	defer c.diskMu.Unlock()
+	path := c.path(e.Key)
+	_, statErr := os.Stat(path)
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if statErr != nil {
+		c.diskFiles++
+	}
+	if c.diskFiles > c.diskSize {
+		c.pruneDisk()
+	}
+}
+
+// pruneDisk removes expired entries and stale temp files and, if more than
+// diskSize entries remain, the oldest ones down to nine tenths of it, so
+// that a full directory is not pruned on every write. Entries are aged by
+// modification time, which is when they were written. Must hold c.diskMu.
+func (c *ResultCache) pruneDisk() {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		name    string
+		modTime time.Time
+	}
+	var files []file
+	now := time.Now()
+	for _, de := range entries {
+		name := filepath.Join(c.dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(de.Name(), ".tmp-") {
+			if now.Sub(info.ModTime()) > staleTempAge {
+				_ = os.Remove(name)
+			}
+			continue
+		}
+		if !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		if !now.Before(info.ModTime().Add(c.ttl)) {
+			_ = os.Remove(name)
+			continue
+		}
+		files = append(files, file{name, info.ModTime()})
+	}
+	if len(files) > c.diskSize {
+		sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+		excess := len(files) - c.diskSize*9/10
+		for _, f := range files[:excess] {
+			_ = os.Remove(f.name)
+		}
+		files = files[excess:]
+	}
+	c.diskFiles = len(files)
+}
+
+// maxAliases bounds the memo of URLs that serve an article under another
+// canonical address.
+const maxAliases = 4096
+
+// aliasIndex maps scraped URLs to the trusted canonical URL of the article
+// they served, so that later lookups and deduplication use the article's
+// identity. The zero value is ready to use.
+type aliasIndex struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// resolve returns the canonical URL recorded for u, or u itself.
+func (a *aliasIndex) resolve(u string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.m[u]; ok {
+		return c
+	}
+	return u
+}
+
+// add records that u serves the article whose canonical URL is canonical.
+func (a *aliasIndex) add(u, canonical string) {
+	if u == canonical {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.m == nil || len(a.m) >= maxAliases {
+		a.m = make(map[string]string)
+	}
+	a.m[u] = canonical
+}
+
+// trustedCanonical returns r's canonical URL if it may identify the article:
+// it must be on the same registrable domain as the address the page was
+// served from, and not a site's home page, which dead articles often
+// declare. Otherwise, and for archived snapshots, it returns "".
+func trustedCanonical(r internal.ArticleResult) string {
+	if r.CanonicalURL == "" || r.Source != "" {
+		return ""
+	}
+	canonical, err := url.Parse(r.CanonicalURL)
+	if err != nil || ((canonical.Path == "" || canonical.Path == "/") && canonical.RawQuery == "") {
+		return ""
+	}
+	served, err := url.Parse(firstNonEmpty(r.FinalURL, r.URL))
+	if err != nil || registrableDomain(canonical.Hostname()) != registrableDomain(served.Hostname()) {
+		return ""
+	}
+	return r.CanonicalURL
+}
+
+// registrableDomain returns the domain under a public suffix that host
+// belongs to, e.g. "example.co.uk" for "news.example.co.uk".
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
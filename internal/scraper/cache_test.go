@@ -0,0 +1,147 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// cacheFiles counts the entries a ResultCache keeps in dir.
+func cacheFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	c, err := NewResultCache(30*time.Millisecond, 10, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", internal.ArticleResult{Content: "v"})
+	if r, ok := c.Get("k"); !ok || r.Content != "v" {
+		t.Fatalf("got %q, %v right after Set", r.Content, ok)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("k"); ok {
+		t.Errorf("entry still served after its TTL")
+	}
+}
+
+func TestResultCacheLRU(t *testing.T) {
+	c, err := NewResultCache(time.Hour, 2, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", internal.ArticleResult{Content: "a"})
+	c.Set("b", internal.ArticleResult{Content: "b"})
+	c.Get("a") // b is now the least recently used
+	c.Set("c", internal.ArticleResult{Content: "c"})
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) found %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestResultCacheReloadsFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResultCache(time.Hour, 10, dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("k", internal.ArticleResult{Title: "Title", Content: "body"})
+
+	// A leftover temp file from a crash is cleaned up on start.
+	stale := filepath.Join(dir, ".tmp-crash")
+	if err := os.WriteFile(stale, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewResultCache(time.Hour, 10, dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := restarted.Get("k"); !ok || r.Title != "Title" || r.Content != "body" {
+		t.Errorf("after restart got %+v, %v", r, ok)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temp file kept: %v", err)
+	}
+}
+
+func TestResultCacheDiskBound(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResultCache(time.Hour, 1, dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 25 {
+		c.Set(fmt.Sprint("k", i), internal.ArticleResult{Content: fmt.Sprint(i)})
+		if n := cacheFiles(t, dir); n > 10 {
+			t.Fatalf("%d files on disk after %d writes, want at most 10", n, i+1)
+		}
+	}
+	restarted, err := NewResultCache(time.Hour, 1, dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := restarted.Get("k24"); !ok || r.Content != "24" {
+		t.Errorf("newest entry lost: %+v, %v", r, ok)
+	}
+	if _, ok := restarted.Get("k0"); ok {
+		t.Errorf("oldest entry kept")
+	}
+}
+
+func TestResultCacheDiskPrunesExpired(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewResultCache(30*time.Millisecond, 1, dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, internal.ArticleResult{Content: k})
+	}
+	time.Sleep(40 * time.Millisecond)
+	c.Set("d", internal.ArticleResult{Content: "d"})
+	if n := cacheFiles(t, dir); n != 1 {
+		t.Errorf("%d files on disk, want only the fresh one", n)
+	}
+}
+
+func TestTrustedCanonical(t *testing.T) {
+	tests := []struct {
+		name string
+		r    internal.ArticleResult
+		want string
+	}{
+		{"same host", internal.ArticleResult{URL: "https://example.com/a?utm=x", CanonicalURL: "https://example.com/a"}, "https://example.com/a"},
+		{"other subdomain", internal.ArticleResult{URL: "https://m.example.co.uk/a", CanonicalURL: "https://www.example.co.uk/a"}, "https://www.example.co.uk/a"},
+		{"served from the final URL", internal.ArticleResult{URL: "https://t.example/x", FinalURL: "https://example.com/a", CanonicalURL: "https://example.com/a"}, "https://example.com/a"},
+		{"other registrable domain", internal.ArticleResult{URL: "https://example.com/a", CanonicalURL: "https://other.com/a"}, ""},
+		{"other domain under a public suffix", internal.ArticleResult{URL: "https://news.example.co.uk/a", CanonicalURL: "https://other.co.uk/a"}, ""},
+		{"redirected away from the canonical's site", internal.ArticleResult{URL: "https://example.com/a", FinalURL: "https://mirror.example/a", CanonicalURL: "https://example.com/a"}, ""},
+		{"home page", internal.ArticleResult{URL: "https://example.com/gone", CanonicalURL: "https://example.com/"}, ""},
+		{"home page without a path", internal.ArticleResult{URL: "https://example.com/gone", CanonicalURL: "https://example.com"}, ""},
+		{"home page with a query", internal.ArticleResult{URL: "https://example.com/?p=12", CanonicalURL: "https://example.com/?p=12"}, "https://example.com/?p=12"},
+		{"archived snapshot", internal.ArticleResult{URL: "https://example.com/a", CanonicalURL: "https://example.com/a", Source: sourceArchive}, ""},
+		{"none", internal.ArticleResult{URL: "https://example.com/a"}, ""},
+	}
+	for _, tt := range tests {
+		if got := trustedCanonical(tt.r); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
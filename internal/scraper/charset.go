@@ -0,0 +1,91 @@
+package scraper
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gogs/chardet"
+	"golang.org/x/net/html/charset"
+)
+
+// metaPrescanBytes is how much of the document is searched for a <meta> charset.
+const metaPrescanBytes = 4096
+
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.\-]+)`)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// toUTF8 converts an HTML body to UTF-8 and returns the canonical name of
+// the charset it was decoded from. The charset is taken from, in order: a
+// byte-order mark, the Content-Type header, a <meta> declaration, and
+// finally a statistical sniff. A declared UTF-8 that does not validate is
+// treated as undeclared, since mislabelled windows-1251 pages are common.
+func toUTF8(body []byte, contentType string) ([]byte, string) {
+	if bytes.HasPrefix(body, utf8BOM) {
+		return body[len(utf8BOM):], "utf-8"
+	}
+
+	for _, label := range []string{headerCharset(contentType), metaCharset(body)} {
+		if label == "" {
+			continue
+		}
+		if out, name, ok := decodeAs(body, label); ok {
+			return out, name
+		}
+	}
+
+	if utf8.Valid(body) {
+		return body, "utf-8"
+	}
+	if r, err := chardet.NewHtmlDetector().DetectBest(body); err == nil {
+		label := r.Charset
+		// chardet does not tell KOI8-U from KOI8-R; KOI8-U's letters are a
+		// superset, so Ukrainian і, ї, є and ґ decode correctly too.
+		if strings.EqualFold(label, "koi8-r") {
+			label = "koi8-u"
+		}
+		if out, name, ok := decodeAs(body, label); ok {
+			return out, name
+		}
+	}
+	return body, ""
+}
+
+// decodeAs decodes body using the encoding named by label. It fails for
+// unknown labels and for UTF-8 labels on invalid UTF-8 input.
+func decodeAs(body []byte, label string) ([]byte, string, bool) {
+	enc, name := charset.Lookup(label)
+	if enc == nil {
+		return nil, "", false
+	}
+	if name == "utf-8" {
+		return body, name, utf8.Valid(body)
+	}
+	out, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, "", false
+	}
+	return out, name, true
+}
+
+func headerCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+func metaCharset(body []byte) string {
+	m := metaCharsetRe.FindSubmatch(body[:min(len(body), metaPrescanBytes)])
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
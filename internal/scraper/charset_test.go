@@ -0,0 +1,59 @@
+package scraper
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const ukrainianText = "Київ - столиця України. Її жителі щодня їздять містом, " +
+	"читають новини й обговорюють події. Ґанок старої будівлі відремонтували, " +
+	"а єдиний міст через річку знову відкрили для руху після довгої перерви. " +
+	"Міська рада повідомила, що роботи завершено вчасно і без перевитрат."
+
+func encodeTest(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestToUTF8(t *testing.T) {
+	page := func(head string) string {
+		return "<html><head>" + head + "<title>Новини</title></head><body><p>" + ukrainianText + "</p></body></html>"
+	}
+	tests := []struct {
+		name, contentType, head string
+		enc                     encoding.Encoding
+		want                    string
+	}{
+		{"windows-1251 in header", "text/html; charset=windows-1251", "", charmap.Windows1251, "windows-1251"},
+		{"KOI8-U in header", "text/html; charset=KOI8-U", "", charmap.KOI8U, "koi8-u"},
+		{"windows-1251 in meta", "text/html", `<meta charset="windows-1251">`, charmap.Windows1251, "windows-1251"},
+		{"KOI8-U in meta", "text/html", `<meta http-equiv="Content-Type" content="text/html; charset=koi8-u">`, charmap.KOI8U, "koi8-u"},
+		{"windows-1251 sniffed", "text/html", "", charmap.Windows1251, "windows-1251"},
+		{"KOI8-U sniffed", "", "", charmap.KOI8U, "koi8-u"},
+		{"windows-1251 mislabelled as utf-8", "text/html; charset=utf-8", "", charmap.Windows1251, "windows-1251"},
+		{"windows-1251 mislabelled in meta", "", `<meta charset="utf-8">`, charmap.Windows1251, "windows-1251"},
+	}
+	for _, tt := range tests {
+		want := page(tt.head)
+		got, name := toUTF8(encodeTest(t, tt.enc, want), tt.contentType)
+		if name != tt.want || string(got) != want {
+			t.Errorf("%s: decoded as %q to %q", tt.name, name, got)
+		}
+	}
+}
+
+func TestToUTF8Passthrough(t *testing.T) {
+	body := "<p>" + ukrainianText + "</p>"
+	if got, name := toUTF8([]byte("\xEF\xBB\xBF"+body), "text/html; charset=windows-1251"); name != "utf-8" || string(got) != body {
+		t.Errorf("BOM: decoded as %q to %q", name, got)
+	}
+	if got, name := toUTF8([]byte(body), ""); name != "utf-8" || string(got) != body {
+		t.Errorf("undeclared UTF-8: decoded as %q to %q", name, got)
+	}
+}
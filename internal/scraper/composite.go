@@ -0,0 +1,187 @@
+package scraper
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+
+	"github.com/val/autoga/internal"
+)
+
+// CompositeExtractor runs several extraction strategies on a page and
+// keeps the candidate body that scores best: readability, site rules,
+// JSON-LD articleBody, embedded framework state, the OpenGraph description
+// and the feed snippet sent with the request.
+type CompositeExtractor struct {
+	base *ReadabilityExtractor
+}
+
+// NewCompositeExtractor creates a CompositeExtractor. opts configure the
+// readability and site rule strategies.
+func NewCompositeExtractor(opts ...ExtractorOption) *CompositeExtractor {
+	return &CompositeExtractor{base: NewReadabilityExtractor(opts...)}
+}
+
+// Extract returns the page's metadata with the best candidate as content.
+// Extractor names the winning strategy and Confidence is its score.
+func (c *CompositeExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	a, err := c.base.analyze(doc)
+	if err != nil {
+		return a.result, err
+	}
+	format := a.result.Format
+	candidates := []candidate{
+		a.rule,
+		a.readability,
+		textCandidate("json_ld", a.meta.Body, a.base, format),
+		textCandidate("json_state", a.state.body, a.base, format),
+		textCandidate("opengraph", a.meta.Description, a.base, format),
+		textCandidate("rss_snippet", doc.Snippet, a.base, format),
+	}
+
+	var best candidate
+	bestScore := -1.0
+	for _, cand := range candidates {
+		if cand.text == "" {
+			continue
+		}
+		// Ties go to the earlier, more structured strategy.
+		if s := score(cand, a.result.Title, a.excerpt); s > bestScore {
+			best, bestScore = cand, s
+		}
+	}
+	return a.finish(best, max(bestScore, 0))
+}
+
+// candidate is one strategy's proposal for the article body.
+type candidate struct {
+	name    string // strategy, reported as ArticleResult.Extractor
+	content string // rendered in the requested format
+	text    string // plain text used for scoring
+	linkLen int    // runes of text inside links
+}
+
+// nodeCandidate renders the children of n as a candidate.
+func nodeCandidate(name string, n *html.Node, format internal.Format) candidate {
+	text := render(n, internal.FormatText)
+	if strings.TrimSpace(text) == "" {
+		return candidate{}
+	}
+	return candidate{name: name, content: render(n, format), text: text, linkLen: linkTextLen(n)}
+}
+
+// textCandidate turns s, which may be plain text or an HTML fragment, into
+// a candidate. Plain text is split into paragraphs at line breaks.
+func textCandidate(name, s string, base *url.URL, format internal.Format) candidate {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return candidate{}
+	}
+	if !strings.Contains(s, "<") {
+		return paragraphCandidate(name, strings.Split(s, "\n"), format)
+	}
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return candidate{}
+	}
+	body := dom.QuerySelector(root, "body")
+	if body == nil {
+		return candidate{}
+	}
+	resolveLinks(body, base)
+	return nodeCandidate(name, body, format)
+}
+
+// paragraphCandidate turns plain-text paragraphs into a candidate.
+func paragraphCandidate(name string, paras []string, format internal.Format) candidate {
+	body := &html.Node{Type: html.ElementNode, Data: "div"}
+	for _, para := range paras {
+		if para = strings.TrimSpace(para); para != "" {
+			p := &html.Node{Type: html.ElementNode, Data: "p"}
+			p.AppendChild(&html.Node{Type: html.TextNode, Data: para})
+			body.AppendChild(p)
+		}
+	}
+	return nodeCandidate(name, body, format)
+}
+
+// linkTextLen counts the runes of text inside <a> elements under n.
+func linkTextLen(n *html.Node) int {
+	if n.Type == html.ElementNode && n.Data == "a" {
+		return utf8.RuneCountInString(tidyInline(textContent(n)))
+	}
+	total := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		total += linkTextLen(c)
+	}
+	return total
+}
+
+// Score weights. Length dominates so that a one-sentence summary does not
+// beat a full article; the other signals separate article text from
+// navigation, related-link lists and unrelated blocks of similar size.
+const (
+	weightLength  = 0.5
+	weightLinks   = 0.2
+	weightExcerpt = 0.2
+	weightTitle   = 0.1
+
+	// lengthScale is the word count at which the length signal reaches ~63%.
+	lengthScale = 250.0
+	// ruleBonus favours a site rule, which a person wrote for this source.
+	ruleBonus = 0.1
+)
+
+// score rates c between 0 and 1 on length, link density, overlap with the
+// page's excerpt and mention of the title's words. Signals that cannot be
+// judged (no excerpt, no title) count as neutral.
+func score(c candidate, title, excerpt string) float64 {
+	if c.text == "" {
+		return 0
+	}
+	words := wordSet(c.text)
+	length := 1 - math.Exp(-float64(len(strings.Fields(c.text)))/lengthScale)
+	density := float64(c.linkLen) / float64(max(utf8.RuneCountInString(c.text), 1))
+
+	s := weightLength*length +
+		weightLinks*(1-min(density, 1)) +
+		weightExcerpt*coverage(wordSet(excerpt), words) +
+		weightTitle*coverage(wordSet(title), words)
+	if c.name == "site_rule" {
+		s += ruleBonus
+	}
+	return math.Round(min(s, 1)*100) / 100
+}
+
+// coverage is the share of want found in have, 0.5 when want is empty.
+func coverage(want, have map[string]bool) float64 {
+	if len(want) == 0 {
+		return 0.5
+	}
+	found := 0
+	for w := range want {
+		if have[w] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(want))
+}
+
+// wordSet returns the distinct lowercased words of s that are at least
+// three letters long; shorter words carry little signal.
+func wordSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,103 @@
+package scraper
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// articleParagraphs is a body long enough for readability to accept.
+var articleParagraphs = strings.Repeat("<p>The city council approved the new transport budget on Tuesday after a debate "+
+	"that ran late into the evening, with members arguing over bus routes, cycle lanes and fares.</p>", 8)
+
+func paragraphs(n int, s string) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = s
+	}
+	return out
+}
+
+func TestScore(t *testing.T) {
+	const title = "Council approves transport budget"
+	const excerpt = "The city council approved the new transport budget."
+	article := paragraphCandidate("readability", paragraphs(8,
+		"The city council approved the new transport budget on Tuesday after a long debate over bus routes and fares."), internal.FormatText)
+	summary := paragraphCandidate("opengraph", []string{excerpt}, internal.FormatText)
+
+	var links strings.Builder
+	for range 40 {
+		links.WriteString(`<li><a href="/news/other">Council transport budget news and more stories</a></li>`)
+	}
+	nav := textCandidate("json_state", "<ul>"+links.String()+"</ul>", mustParse(t, "https://example.com/"), internal.FormatText)
+
+	full, short, navScore := score(article, title, excerpt), score(summary, title, excerpt), score(nav, title, excerpt)
+	if full <= short {
+		t.Errorf("full body scored %v, not above the description's %v", full, short)
+	}
+	if navScore >= full {
+		t.Errorf("link list scored %v, not below the article's %v", navScore, full)
+	}
+	if nav.linkLen == 0 || nav.linkLen < len(nav.text)/2 {
+		t.Errorf("link list has %d link runes of %d", nav.linkLen, len(nav.text))
+	}
+
+	// Unjudgeable signals are neutral rather than penalties.
+	if s := score(article, "", ""); s <= 0 || s >= full {
+		t.Errorf("without title and excerpt scored %v", s)
+	}
+	if s := score(candidate{}, title, excerpt); s != 0 {
+		t.Errorf("empty candidate scored %v", s)
+	}
+
+	// A site rule beats a slightly longer readability body.
+	rule := article
+	rule.name = "site_rule"
+	rule.text = strings.Join(paragraphs(7,
+		"The city council approved the new transport budget on Tuesday after a long debate over bus routes and fares."), "\n\n")
+	if r := score(rule, title, excerpt); r <= full {
+		t.Errorf("site rule scored %v, not above readability's %v", r, full)
+	}
+}
+
+func TestCompositeExtractPicksCandidate(t *testing.T) {
+	const head = `<title>Council approves transport budget</title>
+<meta property="og:description" content="The city council approved the new transport budget.">`
+	nav := strings.Repeat(`<li><a href="/a">Council transport budget news</a></li>`, 30)
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, `[{"domain": "ruled.example", "body": ".story"}]`, time.Now())
+	rules, err := NewSiteRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCompositeExtractor(WithSiteRules(rules))
+
+	tests := []struct {
+		name, url, body, want string
+	}{
+		{"readability over the description", "https://example.com/a", "<article>" + articleParagraphs + "</article>", "readability"},
+		{"description over navigation", "https://example.com/a", "<nav><ul>" + nav + "</ul></nav>", "opengraph"},
+		// JSON-LD repeating the article ties with it; the earlier strategy wins.
+		{"tie", "https://example.com/a", `<script type="application/ld+json">{"@type": "NewsArticle", "articleBody": ` +
+			strconv.Quote(strings.ReplaceAll(strings.ReplaceAll(articleParagraphs, "<p>", ""), "</p>", "\n")) + `}</script>` +
+			"<article>" + articleParagraphs + "</article>", "readability"},
+		// Readability also takes the trailing paragraph, scoring close to the rule.
+		{"site rule", "https://ruled.example/a", `<div class="story">` + articleParagraphs + `</div><p>Read more about the city council and its budget.</p>`, "site_rule"},
+	}
+	for _, tt := range tests {
+		doc := Document{URL: tt.url, Format: internal.FormatText, HTML: []byte("<html><head>" + head + "</head><body>" + tt.body + "</body></html>")}
+		r, err := c.Extract(doc)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if r.Extractor != tt.want || r.Confidence <= 0 || r.Confidence > 1 {
+			t.Errorf("%s: got %s with confidence %v, want %s", tt.name, r.Extractor, r.Confidence, tt.want)
+		}
+	}
+}
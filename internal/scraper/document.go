@@ -0,0 +1,196 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/val/autoga/internal"
+)
+
+// Extractor names of the document extractors.
+const (
+	extractorPDF       = "pdf"
+	extractorPlainText = "plain_text"
+)
+
+// documentHandler extracts one non-HTML media type.
+type documentHandler struct {
+	extractor Extractor
+	binary    bool // the body is passed as fetched instead of decoded to UTF-8
+}
+
+// documentTypes are the non-HTML media types the fetcher accepts and the
+// Scraper extracts.
+var documentTypes = map[string]documentHandler{
+	"application/pdf": {extractor: PDFExtractor{}, binary: true},
+	"text/plain":      {extractor: TextExtractor{}},
+}
+
+// sniffTypes are generic media types whose real type is sniffed from the body.
+var sniffTypes = map[string]bool{
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+}
+
+// mediaType returns the lowercased media type of a Content-Type header.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// documentType decides how a fetched body is extracted. A missing or
+// generic Content-Type is replaced by what the body looks like.
+func documentType(contentType string, body []byte) string {
+	mt := mediaType(contentType)
+	if mt != "" && !sniffTypes[mt] {
+		return mt
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(body, "\x00\t\n\r "), []byte("%PDF-")) {
+		return "application/pdf"
+	}
+	if mt == "" {
+		// Many servers omit the header on HTML pages.
+		return "text/html"
+	}
+	return mediaType(http.DetectContentType(body))
+}
+
+// PDFExtractor extracts the text and document information of PDF files.
+// It handles the text of Flate-compressed content streams in simple and
+// composite fonts; scanned PDFs have no text and fail with ErrExtractionEmpty.
+type PDFExtractor struct{}
+
+// Extract parses doc.HTML, which holds the raw PDF bytes.
+func (e PDFExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	return e.ExtractContext(context.Background(), doc)
+}
+
+// ExtractContext is Extract, giving up when ctx is done. A bug the parser
+// hits on a malformed file is reported as an error rather than a panic, since
+// extraction runs on shared worker goroutines.
+func (PDFExtractor) ExtractContext(ctx context.Context, doc Document) (r internal.ArticleResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errReadPDF(doc.URL, fmt.Errorf("parser panic: %v", p))
+		}
+	}()
+	r = internal.ArticleResult{URL: doc.URL, Format: documentFormat(doc.Format)}
+	pdf, err := parsePDF(ctx, doc.HTML)
+	if err != nil {
+		return r, errReadPDF(doc.URL, err)
+	}
+
+	var pages [][]pdfLine
+	for _, page := range pdf.pages() {
+		pages = append(pages, pdf.pageLines(page))
+	}
+	if err := pdf.err(); err != nil {
+		return r, errReadPDF(doc.URL, err)
+	}
+	paras := pdfParagraphs(pages)
+	var lines []string
+	for _, page := range pages {
+		for _, l := range page {
+			lines = append(lines, tidyInline(l.text))
+		}
+	}
+
+	info := pdf.info()
+	root := pdf.dict(pdf.trailer["Root"])
+	r.Title = firstNonEmpty(documentTitle(pdf.text(info, "Title")), firstLine(lines))
+	r.Byline = pdf.text(info, "Author")
+	r.Excerpt = pdf.text(info, "Subject")
+	r.PublishedAt = pdfDate(pdf.text(info, "CreationDate"))
+	r.ModifiedAt = pdfDate(pdf.text(info, "ModDate"))
+	r.Language = normalizeLanguage(pdf.text(root, "Lang"))
+	r.Keywords = splitKeywords(pdf.text(info, "Keywords"))
+	return finishDocument(r, paragraphCandidate(extractorPDF, paras, r.Format))
+}
+
+// errReadPDF is the error for a PDF at target that could not be read. A done
+// context keeps its own classification.
+func errReadPDF(target string, err error) error {
+	err = fmt.Errorf("read PDF at %s: %w", target, err)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &Error{Code: internal.ErrExtractionEmpty, Err: err}
+}
+
+// TextExtractor extracts plain-text documents. Blank lines separate
+// paragraphs; the first line becomes the title if it is short.
+type TextExtractor struct{}
+
+// Extract splits doc.HTML, which holds UTF-8 text, into paragraphs.
+func (TextExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	r := internal.ArticleResult{URL: doc.URL, Format: documentFormat(doc.Format)}
+	var paras []string
+	for _, block := range blankLineRe.Split(strings.ReplaceAll(string(doc.HTML), "\r\n", "\n"), -1) {
+		if p := tidyInline(block); p != "" {
+			paras = append(paras, p)
+		}
+	}
+	r.Title = firstLine(paras)
+	return finishDocument(r, paragraphCandidate(extractorPlainText, paras, r.Format))
+}
+
+var blankLineRe = regexp.MustCompile(`\n[ \t]*\n`)
+
+// maxTitleRunes is the longest first line still taken for a title.
+const maxTitleRunes = 200
+
+// firstLine returns the first non-empty line if it is short enough to be a title.
+func firstLine(lines []string) string {
+	for _, l := range lines {
+		if l != "" {
+			if utf8.RuneCountInString(l) <= maxTitleRunes {
+				return l
+			}
+			break
+		}
+	}
+	return ""
+}
+
+// filenameTitleRe matches document titles that are just a file name, as
+// office suites leave them.
+var filenameTitleRe = regexp.MustCompile(`(?i)(^microsoft (word|powerpoint|excel) - |\.(docx?|pdf|pptx?|xlsx?|odt|rtf|indd|tex)$)`)
+
+// documentTitle drops titles that only name the source file.
+func documentTitle(title string) string {
+	if filenameTitleRe.MatchString(title) {
+		return ""
+	}
+	return title
+}
+
+func documentFormat(f internal.Format) internal.Format {
+	if f == "" {
+		return internal.FormatTextFlat
+	}
+	return f
+}
+
+// finishDocument fills the content fields from c, failing if c is empty.
+func finishDocument(r internal.ArticleResult, c candidate) (internal.ArticleResult, error) {
+	r.Content = c.content
+	if r.Content == "" {
+		return r, &Error{
+			Code: internal.ErrExtractionEmpty,
+			Err:  fmt.Errorf("no text found in document at %s", r.URL),
+		}
+	}
+	r.Extractor = c.name
+	r.Confidence = score(c, r.Title, r.Excerpt)
+	return r, nil
+}
@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/val/autoga/internal"
+)
+
+// Error is a scraping failure tagged with its ErrorCode. Fetcher and
+// Extractor implementations may return it directly; any other error is
+// classified by classify.
+type Error struct {
+	Code      internal.ErrorCode
+	Retryable bool
+	Err       error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// errPastDeadline reports a politeness wait for host that would end after
+// ctx's deadline. The host is merely busy, so a later request may succeed.
+func errPastDeadline(host string) error {
+	return &Error{
+		Code:      internal.ErrTimeout,
+		Retryable: true,
+		Err:       fmt.Errorf("next request slot for %s is past the deadline", host),
+	}
+}
+
+// classify maps err onto the error taxonomy.
+func classify(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: errorCode(err), Retryable: retryable(err), Err: err}
+}
+
+func errorCode(err error) internal.ErrorCode {
+	var se *statusError
+	if errors.As(err, &se) {
+		switch {
+		case se.status == http.StatusPaymentRequired:
+			return internal.ErrPaywall
+		case se.status == http.StatusUnauthorized, se.status == http.StatusForbidden,
+			se.status == http.StatusUnavailableForLegalReasons, se.challenge:
+			return internal.ErrBlocked
+		case se.status >= 500:
+			return internal.ErrHTTP5xx
+		default:
+			return internal.ErrHTTP4xx
+		}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return internal.ErrTimeout
+		}
+		return internal.ErrDNS
+	}
+
+	var (
+		recordErr   tls.RecordHeaderError
+		alertErr    tls.AlertError
+		verifyErr   *tls.CertificateVerificationError
+		unknownAuth x509.UnknownAuthorityError
+		hostErr     x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuth) || errors.As(err, &hostErr) || errors.As(err, &invalidErr) {
+		return internal.ErrTLS
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return internal.ErrTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return internal.ErrNetwork
+	}
+	if retryable(err) {
+		// io.EOF and friends: the peer dropped the connection.
+		return internal.ErrNetwork
+	}
+	return internal.ErrUnknown
+}
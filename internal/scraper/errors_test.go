@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestClassify(t *testing.T) {
+	// urlErr wraps err the way http.Client.Do reports it.
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com/a", Err: err}
+	}
+	status := func(code int) error { return &statusError{status: code, url: "https://example.com/a"} }
+
+	tests := []struct {
+		name      string
+		err       error
+		code      internal.ErrorCode
+		retryable bool
+	}{
+		{"402", status(402), internal.ErrPaywall, false},
+		{"403", status(403), internal.ErrBlocked, false},
+		{"401", status(401), internal.ErrBlocked, false},
+		{"451", status(451), internal.ErrBlocked, false},
+		{"challenge", &statusError{status: 503, challenge: true}, internal.ErrBlocked, true},
+		{"404", status(404), internal.ErrHTTP4xx, false},
+		{"410", status(410), internal.ErrHTTP4xx, false},
+		{"429", status(429), internal.ErrHTTP4xx, true},
+		{"500", status(500), internal.ErrHTTP5xx, false},
+		{"502", status(502), internal.ErrHTTP5xx, true},
+		{"503", status(503), internal.ErrHTTP5xx, true},
+		{"504", status(504), internal.ErrHTTP5xx, true},
+		{"wrapped status", fmt.Errorf("fetch: %w", status(404)), internal.ErrHTTP4xx, false},
+		{"DNS not found", urlErr(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nx.example", IsNotFound: true}}), internal.ErrDNS, false},
+		{"DNS timeout", urlErr(&net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}), internal.ErrTimeout, true},
+		{"unknown authority", urlErr(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), internal.ErrTLS, false},
+		{"hostname mismatch", urlErr(x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}), internal.ErrTLS, false},
+		{"expired certificate", urlErr(x509.CertificateInvalidError{Reason: x509.Expired}), internal.ErrTLS, false},
+		{"not TLS", urlErr(tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}), internal.ErrTLS, false},
+		{"context deadline", urlErr(context.DeadlineExceeded), internal.ErrTimeout, true},
+		{"dial timeout", urlErr(&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}), internal.ErrTimeout, true},
+		{"context canceled", urlErr(context.Canceled), internal.ErrUnknown, false},
+		{"connection refused", urlErr(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), internal.ErrNetwork, false},
+		{"connection reset", urlErr(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), internal.ErrNetwork, true},
+		{"unexpected EOF", urlErr(io.ErrUnexpectedEOF), internal.ErrNetwork, true},
+		{"EOF", urlErr(io.EOF), internal.ErrNetwork, true},
+		{"other", errors.New("boom"), internal.ErrUnknown, false},
+		{"tagged", fmt.Errorf("wrapped: %w", &Error{Code: internal.ErrTooLarge, Err: errors.New("too big")}), internal.ErrTooLarge, false},
+		{"past deadline", errPastDeadline("example.com"), internal.ErrTimeout, true},
+	}
+	for _, tt := range tests {
+		e := classify(tt.err)
+		if e.Code != tt.code || e.Retryable != tt.retryable {
+			t.Errorf("%s: got %s, retryable %v, want %s, %v", tt.name, e.Code, e.Retryable, tt.code, tt.retryable)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/val/autoga/internal"
+)
+
+// sanitizeReplacer implements internal.EscapeSanitize: double-quotes become
+// single-quotes and backslashes are dropped, so text can be pasted into a
+// JSON string in Make.com templates without breaking it.
+var sanitizeReplacer = strings.NewReplacer(`"`, `'`, `\`, ``)
+
+// escapeResult rewrites the text fields of r for the given escape mode.
+// Results are cached unescaped, so this runs on every response.
+func escapeResult(r internal.ArticleResult, mode internal.Escape) internal.ArticleResult {
+	var fn func(string) string
+	switch mode {
+	case internal.EscapeRaw:
+		return r
+	case internal.EscapeJSONString:
+		fn = jsonString
+	default:
+		fn = sanitizeReplacer.Replace
+	}
+	r.Title = fn(r.Title)
+	r.Byline = fn(r.Byline)
+	r.Content = fn(r.Content)
+	r.Excerpt = fn(r.Excerpt)
+	r.SiteName = fn(r.SiteName)
+	r.Section = fn(r.Section)
+	if r.Keywords != nil {
+		keywords := make([]string, len(r.Keywords))
+		for i, kw := range r.Keywords {
+			keywords[i] = fn(kw)
+		}
+		r.Keywords = keywords
+	}
+	return r
+}
+
+// jsonString returns s encoded as the body of a JSON string literal,
+// without the surrounding quotes.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return ""
+	}
+	out := bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+	return string(out[1 : len(out)-1])
+}
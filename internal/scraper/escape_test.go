@@ -0,0 +1,47 @@
+package scraper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestEscapeResult(t *testing.T) {
+	in := internal.ArticleResult{
+		Title:    `He said "hi"`,
+		Content:  "C:\\path\\to <b>file</b>\nline two\t\u2028end",
+		Keywords: []string{`"quoted"`},
+		URL:      `https://example.com/?q="x"`,
+	}
+	tests := []struct {
+		mode                    internal.Escape
+		title, content, keyword string
+	}{
+		{internal.EscapeRaw, in.Title, in.Content, in.Keywords[0]},
+		{"", `He said 'hi'`, "C:pathto <b>file</b>\nline two\t\u2028end", `'quoted'`},
+		{internal.EscapeSanitize, `He said 'hi'`, "C:pathto <b>file</b>\nline two\t\u2028end", `'quoted'`},
+		{internal.EscapeJSONString, `He said \"hi\"`, `C:\\path\\to <b>file</b>\nline two\t\u2028end`, `\"quoted\"`},
+	}
+	for _, tt := range tests {
+		r := escapeResult(in, tt.mode)
+		if r.Title != tt.title || r.Content != tt.content || r.Keywords[0] != tt.keyword {
+			t.Errorf("%q: got %q, %q, %q, want %q, %q, %q", tt.mode, r.Title, r.Content, r.Keywords[0], tt.title, tt.content, tt.keyword)
+		}
+		if r.URL != in.URL {
+			t.Errorf("%q: URL changed to %q", tt.mode, r.URL)
+		}
+	}
+	if in.Keywords[0] != `"quoted"` {
+		t.Errorf("escaping modified the caller's keywords")
+	}
+}
+
+func TestJSONStringEmbeds(t *testing.T) {
+	for _, s := range []string{`plain`, `"quotes" and \backslashes\`, "control \x01\x1f and \u2028", "<html> & unicode ї"} {
+		var back string
+		if err := json.Unmarshal([]byte(`"`+jsonString(s)+`"`), &back); err != nil || back != s {
+			t.Errorf("%q: embedded as %q, read back %q, %v", s, jsonString(s), back, err)
+		}
+	}
+}
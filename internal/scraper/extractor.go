@@ -5,56 +5,186 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-shiori/go-readability"
+	"golang.org/x/net/html"
 
 	"github.com/val/autoga/internal"
 )
 
-// ReadabilityExtractor uses go-readability to extract article content from HTML.
-type ReadabilityExtractor struct{}
+// ReadabilityExtractor uses go-readability to extract article content from
+// HTML, after any matching site rule has had its say.
+type ReadabilityExtractor struct {
+	rules *SiteRules
+}
+
+// ExtractorOption configures optional ReadabilityExtractor behaviour.
+type ExtractorOption func(*ReadabilityExtractor)
+
+// WithSiteRules consults r before readability. Fields a matching rule
+// extracts take precedence; the rest still come from readability.
+func WithSiteRules(r *SiteRules) ExtractorOption {
+	return func(e *ReadabilityExtractor) { e.rules = r }
+}
 
 // NewReadabilityExtractor creates a ReadabilityExtractor.
-func NewReadabilityExtractor() *ReadabilityExtractor {
-	return &ReadabilityExtractor{}
+func NewReadabilityExtractor(opts ...ExtractorOption) *ReadabilityExtractor {
+	e := &ReadabilityExtractor{}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
 }
 
-// sanitize removes characters that break JSON string embedding in Make.com templates:
-// double-quotes become single-quotes, backslashes are dropped.
-var sanitizeReplacer = strings.NewReplacer(`"`, `'`, `\`, ``)
+// challengeTitles are page titles served by bot-protection interstitials.
+var challengeTitles = []string{
+	"just a moment...",
+	"attention required! | cloudflare",
+	"access denied",
+	"are you a robot?",
+}
 
-func sanitize(s string) string { return sanitizeReplacer.Replace(s) }
+// Extract parses the HTML and returns an ArticleResult populated with
+// readable content in the document's format. A site rule's body wins;
+// otherwise readability's, unless it looks like boilerplate, in which case
+// the excerpt is used.
+func (e *ReadabilityExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	a, err := e.analyze(doc)
+	if err != nil {
+		return a.result, err
+	}
 
-// Extract parses the HTML and returns an ArticleResult populated with readable content.
-func (e *ReadabilityExtractor) Extract(rawURL string, html []byte) (internal.ArticleResult, error) {
+	best := a.rule
+	if best.text == "" {
+		best = a.readability
+		excerpt := a.result.Excerpt
+		// If readability returned text that doesn't contain the excerpt's opening,
+		// it likely extracted boilerplate (navigation, sidebars) instead of the
+		// article body. Fall back to excerpt in that case.
+		if best.text == "" || (excerpt != "" && !strings.Contains(
+			strings.ToLower(best.text),
+			strings.ToLower(excerpt[:min(40, len(excerpt))]),
+		)) {
+			best = textCandidate("excerpt", excerpt, a.base, a.result.Format)
+		}
+	}
+	return a.finish(best, score(best, a.result.Title, a.excerpt))
+}
+
+// analysis is a parsed page: its metadata plus the candidate bodies found
+// by readability and the site rules.
+type analysis struct {
+	result      internal.ArticleResult // everything except the content fields
+	meta        metadata
+	base        *url.URL
+	excerpt     string // summary used to judge candidates
+	rule        candidate
+	readability candidate
+	state       stateArticle
+	walls       wallMarkers
+}
+
+// analyze runs everything that needs the DOM. On error a.result holds
+// whatever is already known about the page.
+func (e *ReadabilityExtractor) analyze(doc Document) (*analysis, error) {
+	rawURL := doc.URL
+	a := &analysis{result: internal.ArticleResult{URL: rawURL}}
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
-		return internal.ArticleResult{URL: rawURL}, fmt.Errorf("parse URL: %w", err)
+		return a, fmt.Errorf("parse URL: %w", err)
 	}
+	a.base = parsed
 
-	article, err := readability.FromReader(bytes.NewReader(html), parsed)
+	root, err := html.Parse(bytes.NewReader(doc.HTML))
 	if err != nil {
-		return internal.ArticleResult{URL: rawURL}, fmt.Errorf("readability: %w", err)
+		return a, &Error{
+			Code: internal.ErrExtractionEmpty,
+			Err:  fmt.Errorf("parse HTML: %w", err),
+		}
+	}
+	meta := extractMetadata(root, parsed)
+	a.meta = meta
+	a.state = embeddedState(root)
+	a.walls = findWallMarkers(root, meta)
+	if doc.Snapshot {
+		a.walls.locked, a.walls.paywall = "", ""
 	}
 
-	content := strings.Join(strings.Fields(article.TextContent), " ")
-	excerpt := article.Excerpt
-	// If readability returned text that doesn't contain the excerpt's opening,
-	// it likely extracted boilerplate (navigation, sidebars) instead of the
-	// article body. Fall back to excerpt in that case.
-	if content == "" || (excerpt != "" && !strings.Contains(
-		strings.ToLower(content),
-		strings.ToLower(excerpt[:min(40, len(excerpt))]),
-	)) {
-		content = excerpt
+	format := doc.Format
+	if format == "" {
+		format = internal.FormatTextFlat
 	}
 
-	return internal.ArticleResult{
+	// The rule runs first: its removals also keep readability away from
+	// comment threads and "read also" blocks.
+	var ruled ruleResult
+	if rule := e.rules.match(parsed); rule != nil {
+		ruled = rule.apply(root, parsed)
+		if ruled.body != nil {
+			a.rule = nodeCandidate("site_rule", ruled.body, format)
+		}
+	}
+
+	// A readability failure only leaves its candidate empty; other
+	// candidates may still have the text.
+	article, err := readability.FromDocument(root, parsed)
+	if err == nil && article.Node != nil {
+		a.readability = nodeCandidate("readability", article.Node, format)
+	}
+
+	title := firstNonEmpty(ruled.title, article.Title, a.state.title)
+	for _, t := range challengeTitles {
+		if strings.ToLower(title) == t {
+			return a, &Error{
+				Code: internal.ErrBlocked,
+				Err:  fmt.Errorf("bot challenge page at %s", rawURL),
+			}
+		}
+	}
+
+	a.excerpt = firstNonEmpty(meta.Description, article.Excerpt)
+	a.result = internal.ArticleResult{
 		URL:      rawURL,
-		Title:    sanitize(article.Title),
-		Byline:   sanitize(article.Byline),
-		Content:  sanitize(content),
-		Excerpt:  sanitize(article.Excerpt),
-		SiteName: sanitize(article.SiteName),
-	}, nil
+		Title:    title,
+		Byline:   firstNonEmpty(ruled.author, article.Byline),
+		Format:   format,
+		Excerpt:  article.Excerpt,
+		SiteName: article.SiteName,
+
+		PublishedAt:  firstNonEmpty(ruled.date, meta.Published, formatTime(article.PublishedTime), a.state.date),
+		ModifiedAt:   firstNonEmpty(meta.Modified, formatTime(article.ModifiedTime)),
+		Language:     firstNonEmpty(meta.Language, normalizeLanguage(article.Language)),
+		ImageURL:     firstNonEmpty(meta.Image, article.Image),
+		CanonicalURL: meta.Canonical,
+		Keywords:     meta.Keywords,
+		Section:      meta.Section,
+
+		ExtractionRule: ruled.name,
+	}
+	return a, nil
+}
+
+// finish fills the content fields from c, flags it if it is a paywall
+// teaser or consent dialog, and fails if c is empty.
+func (a *analysis) finish(c candidate, confidence float64) (internal.ArticleResult, error) {
+	r := a.result
+	r.Wall, r.WallReason = a.walls.verdict(c.text, a.base)
+	r.Content = c.content
+	if r.Content == "" {
+		return r, &Error{
+			Code: internal.ErrExtractionEmpty,
+			Err:  fmt.Errorf("no article text found at %s", r.URL),
+		}
+	}
+	r.Extractor = c.name
+	r.Confidence = confidence
+	return r, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
 }
@@ -6,66 +6,301 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"time"
 
+	"github.com/val/autoga/internal"
+
 	"github.com/val/autoga/internal/useragent"
 )
 
 const maxBodyBytes = 5 * 1024 * 1024 // 5 MB
 
+// maxRedirects matches net/http's default redirect limit.
+const maxRedirects = 10
+
+// maxDrainBytes is how much of an unwanted body is read so the connection
+// can be reused; a longer one is abandoned.
+const maxDrainBytes = 64 * 1024
+
+// htmlTypes are the Content-Types accepted as HTML. A missing Content-Type
+// is accepted too, since many servers omit it.
+var htmlTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+}
+
+// acceptedType reports whether a body of media type mt is worth reading:
+// HTML, a document type with an extractor, or a generic type to sniff.
+func acceptedType(mt string) bool {
+	_, isDocument := documentTypes[mt]
+	return htmlTypes[mt] || isDocument || sniffTypes[mt]
+}
+
+// errUnsupportedType is the not_html error for a Content-Type no extractor handles.
+func errUnsupportedType(contentType, target string) error {
+	return &Error{
+		Code: internal.ErrNotHTML,
+		Err:  fmt.Errorf("unsupported content type %q from %s: only HTML, PDF and plain text are extracted", contentType, target),
+	}
+}
+
 // HTTPFetcher fetches URLs using a shared http.Client with configurable timeout.
 type HTTPFetcher struct {
-	client *http.Client
+	client   *http.Client
+	timeout  time.Duration
+	limiter  *HostLimiter
+	retry    RetryPolicy
+	unwrap   *Unwrappers
+	guard    *NetGuard
+	robots   *RobotsChecker
+	policies *Policies
+}
+
+// FetcherOption configures optional HTTPFetcher behaviour.
+type FetcherOption func(*HTTPFetcher)
+
+// WithHostLimiter makes the fetcher wait on l before every request.
+func WithHostLimiter(l *HostLimiter) FetcherOption {
+	return func(f *HTTPFetcher) { f.limiter = l }
+}
+
+// WithRetry enables retries of transient failures according to p.
+func WithRetry(p RetryPolicy) FetcherOption {
+	return func(f *HTTPFetcher) { f.retry = p }
+}
+
+// WithFetchUnwrappers sets the registry used to unwrap redirector and
+// shortener URLs before fetching. It should be the same registry given to
+// the Scraper via WithUnwrappers.
+func WithFetchUnwrappers(u *Unwrappers) FetcherOption {
+	return func(f *HTTPFetcher) { f.unwrap = u }
+}
+
+// WithNetGuard replaces the default NetGuard, e.g. with one that has an
+// allowlist for intranet hosts.
+func WithNetGuard(g *NetGuard) FetcherOption {
+	return func(f *HTTPFetcher) { f.guard = g }
+}
+
+// WithRobots makes the fetcher obey robots.txt as read by c: disallowed
+// URLs, including redirect targets, fail with ErrBlockedByRobots and
+// Crawl-delay spaces out requests to a host.
+func WithRobots(c *RobotsChecker) FetcherOption {
+	return func(f *HTTPFetcher) { f.robots = c }
+}
+
+// WithFetchPolicies applies per-domain timeouts, User-Agents and headers
+// from p, and fails redirects to domains p skips with ErrSkipped. It should
+// be the same set given to the Scraper via WithPolicies.
+func WithFetchPolicies(p *Policies) FetcherOption {
+	return func(f *HTTPFetcher) { f.policies = p }
 }
 
 // NewHTTPFetcher creates an HTTPFetcher with the given per-request timeout.
-func NewHTTPFetcher(timeout time.Duration) *HTTPFetcher {
-	return &HTTPFetcher{
-		client: &http.Client{Timeout: timeout},
+// Requests always go through a NetGuard; without WithNetGuard only public
+// addresses are reachable.
+func NewHTTPFetcher(timeout time.Duration, opts ...FetcherOption) *HTTPFetcher {
+	f := &HTTPFetcher{timeout: timeout}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.guard == nil {
+		f.guard = NewNetGuard()
+	}
+	// The timeout is applied per attempt in fetchOnce so domain policies can change it.
+	f.client = &http.Client{Transport: f.guard.Transport()}
+	// Each hop is vetted like the first URL: a redirect may lead to a domain
+	// the policies skip or that robots.txt disallows.
+	f.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		if reason := f.policies.skipReason(req.URL.Hostname()); reason != "" {
+			return &Error{
+				Code: internal.ErrSkipped,
+				Err:  fmt.Errorf("skipped redirect to %s: %s", req.URL, reason),
+			}
+		}
+		if f.robots != nil {
+			return f.robots.Allowed(req.Context(), req.URL)
+		}
+		return nil
+	}
+	if f.unwrap == nil {
+		f.unwrap = NewUnwrappers(f.client)
 	}
+	return f
 }
 
-// unwrapGoogleURL extracts the real destination from Google redirect URLs
-// (https://www.google.com/url?...&url=<target>&...). Returns the input unchanged
-// if it is not a Google redirect.
-func unwrapGoogleURL(raw string) string {
-	u, err := url.Parse(raw)
-	if err != nil {
-		return raw
-	}
-	if u.Host != "www.google.com" || u.Path != "/url" {
-		return raw
+// fetchRequest is what FetchWith adds to a fetch.
+type fetchRequest struct {
+	header http.Header // set over the default headers
+	types  []string    // media types accepted besides those of acceptedType
+}
+
+// Fetch performs an HTTP GET and returns the body. Bodies over maxBodyBytes
+// and non-HTML responses are rejected. Transient failures are retried according to the fetcher's RetryPolicy.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	return f.fetch(ctx, rawURL, fetchRequest{})
+}
+
+// FetchWith is Fetch for an endpoint that needs its own request headers,
+// such as an Accept header asking for JSON, and answers with one of types.
+// Domain policy headers still override header. Unlike Fetch, whose callers
+// vet the page's domain first, it also fails with ErrSkipped for a domain
+// the policies skip, since an endpoint may live on another domain.
+func (f *HTTPFetcher) FetchWith(ctx context.Context, rawURL string, header http.Header, types ...string) (Page, error) {
+	if u, err := url.Parse(rawURL); err == nil {
+		if reason := f.policies.skipReason(u.Hostname()); reason != "" {
+			return Page{}, &Error{
+				Code: internal.ErrSkipped,
+				Err:  fmt.Errorf("skipped %s: %s", rawURL, reason),
+			}
+		}
 	}
-	if target := u.Query().Get("url"); target != "" {
-		return target
+	return f.fetch(ctx, rawURL, fetchRequest{header: header, types: types})
+}
+
+func (f *HTTPFetcher) fetch(ctx context.Context, rawURL string, fr fetchRequest) (Page, error) {
+	var page Page
+	target := f.unwrap.Unwrap(ctx, rawURL)
+
+	for {
+		page.Attempts++
+		body, err := f.fetchOnce(ctx, target, fr, &page)
+		if err == nil {
+			page.Body = body
+			return page, nil
+		}
+		if page.Attempts >= f.retry.MaxAttempts || ctx.Err() != nil || !retryable(err) {
+			return page, err
+		}
+		wait, ok := f.retry.delay(ctx, page.Attempts, err)
+		if !ok {
+			return page, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return page, err
+		case <-timer.C:
+		}
 	}
-	return raw
 }
 
-// Fetch performs an HTTP GET and returns the body, capped at maxBodyBytes.
-func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
-	target := unwrapGoogleURL(rawURL)
+// fetchOnce performs a single attempt, accumulating limiter wait into page.
+func (f *HTTPFetcher) fetchOnce(ctx context.Context, target string, fr fetchRequest, page *Page) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
 	req.Header.Set("User-Agent", useragent.Next())
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf;q=0.8,text/plain;q=0.8,*/*;q=0.7")
 	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+	for k, vs := range fr.header {
+		req.Header[http.CanonicalHeaderKey(k)] = vs
+	}
+	policy, _ := f.policies.Lookup(req.URL.Hostname())
+	if policy.UserAgent != "" {
+		req.Header.Set("User-Agent", policy.UserAgent)
+	}
+	for k, v := range policy.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if f.robots != nil {
+		waited, err := f.robots.Wait(ctx, req.URL)
+		page.LimiterWait += waited
+		if err != nil {
+			return nil, err
+		}
+	}
+	if f.limiter != nil {
+		waited, release, err := f.limiter.Acquire(ctx, req.URL.Hostname())
+		page.LimiterWait += waited
+		if err != nil {
+			return nil, fmt.Errorf("wait for %s: %w", req.URL.Hostname(), err)
+		}
+		defer release()
+	}
+
+	// The timeout starts once any limiter wait is over and covers reading the body.
+	timeout := f.timeout
+	if policy.Timeout > 0 {
+		timeout = policy.Timeout
+	}
+	if timeout > 0 {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		req = req.WithContext(attemptCtx)
+	}
 
 	resp, err := f.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch %s: %w", target, err)
 	}
 	defer resp.Body.Close()
+	page.Status = resp.StatusCode
+	page.Redirects = redirectChain(resp)
+	page.FinalURL = resp.Request.URL.String()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, target)
+		drain(resp.Body)
+		return nil, &statusError{
+			status:     resp.StatusCode,
+			url:        target,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			challenge:  resp.Header.Get("Cf-Mitigated") == "challenge",
+		}
+	}
+
+	page.ContentType = resp.Header.Get("Content-Type")
+	if ct := page.ContentType; ct != "" && !acceptedType(mediaType(ct)) && !slices.Contains(fr.types, mediaType(ct)) {
+		return nil, errUnsupportedType(ct, target)
 	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if resp.ContentLength > maxBodyBytes {
+		return nil, errTooLarge(target)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
 	if err != nil {
 		return nil, fmt.Errorf("read body: %w", err)
 	}
+	if len(body) > maxBodyBytes {
+		return nil, errTooLarge(target)
+	}
 	return body, nil
 }
+
+// drain reads what is left of a small response body, letting the
+// transport reuse its connection once the body is closed.
+func drain(body io.Reader) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+}
+
+// redirectChain lists every URL requested to obtain resp, oldest first.
+// It returns nil when no redirect was followed.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil; {
+		chain = append(chain, req.URL.String())
+		if req.Response == nil {
+			break
+		}
+		req = req.Response.Request
+	}
+	if len(chain) < 2 {
+		return nil
+	}
+	slices.Reverse(chain)
+	return chain
+}
+
+func errTooLarge(target string) error {
+	return &Error{
+		Code: internal.ErrTooLarge,
+		Err:  fmt.Errorf("body of %s exceeds %d bytes", target, maxBodyBytes),
+	}
+}
@@ -0,0 +1,36 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestFetchRedirectChain(t *testing.T) {
+	var srv *stubPlatform
+	redirect := func(to string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, srv.URL+to, http.StatusMovedPermanently)
+		}
+	}
+	srv = newStubPlatform(t, map[string]http.HandlerFunc{
+		"/a": redirect("/b?x=1"),
+		"/b": redirect("/c"),
+		"/c": serveBody("text/html", "<p>article</p>"),
+	})
+
+	page, err := stubFetcher().Fetch(context.Background(), srv.URL+"/a")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []string{srv.URL + "/a", srv.URL + "/b?x=1", srv.URL + "/c"}
+	if !slices.Equal(page.Redirects, want) || page.FinalURL != srv.URL+"/c" {
+		t.Errorf("got chain %q ending at %q, want %q", page.Redirects, page.FinalURL, want)
+	}
+
+	page, err = stubFetcher().Fetch(context.Background(), srv.URL+"/c")
+	if err != nil || page.Redirects != nil || page.FinalURL != srv.URL+"/c" {
+		t.Errorf("without redirects got chain %q ending at %q, %v", page.Redirects, page.FinalURL, err)
+	}
+}
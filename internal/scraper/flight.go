@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// flightGroup coalesces concurrent scrapes of the same key so that one
+// fetch and extraction serves every caller waiting on it.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done    chan struct{}
+	result  internal.ArticleResult
+	waiters int
+	ctx     *flightContext
+}
+
+// do runs fn once per key among concurrent callers and returns its result.
+// fn runs on a context detached from any single caller: a caller that gives
+// up returns ctx.Err() immediately, and fn is cancelled only once every
+// waiting caller has given up. Its deadline is the latest among the
+// callers, since callers joining later may be willing to wait longer than
+// the first one; a caller without a deadline removes it.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) internal.ArticleResult) (internal.ArticleResult, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	c, ok := g.calls[key]
+	if !ok {
+		c = &flight{done: make(chan struct{}), ctx: newFlightContext(ctx)}
+		g.calls[key] = c
+	}
+	c.ctx.join(ctx)
+	if !ok {
+		go func() {
+			c.result = fn(c.ctx)
+			c.ctx.cancel(context.Canceled)
+			g.mu.Lock()
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.result, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.ctx.cancel(context.Canceled)
+			// Let the next caller start afresh rather than join a cancelled flight.
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return internal.ArticleResult{}, ctx.Err()
+	}
+}
+
+// flightContext is the context a flight runs on. It keeps the values of the
+// caller that started the flight but none of its cancellation, and expires
+// at the latest deadline of the callers that joined.
+type flightContext struct {
+	context.Context
+	done chan struct{}
+
+	mu        sync.Mutex
+	err       error
+	deadline  time.Time // latest deadline of the callers, zero if none
+	unbounded bool      // a caller without a deadline joined
+	timer     *time.Timer
+}
+
+func newFlightContext(ctx context.Context) *flightContext {
+	return &flightContext{Context: context.WithoutCancel(ctx), done: make(chan struct{})}
+}
+
+// join extends the deadline to cover the caller whose context is ctx.
+func (c *flightContext) join(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unbounded || c.err != nil {
+		return
+	}
+	d, ok := ctx.Deadline()
+	if !ok {
+		c.unbounded = true
+		c.deadline = time.Time{}
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		return
+	}
+	if !d.After(c.deadline) {
+		return
+	}
+	c.deadline = d
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.timer = time.AfterFunc(time.Until(d), func() {
+		c.mu.Lock()
+		expired := c.deadline.Equal(d)
+		c.mu.Unlock()
+		if expired {
+			c.cancel(context.DeadlineExceeded)
+		}
+	})
+}
+
+// cancel ends the context with err unless it has already ended.
+func (c *flightContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+func (c *flightContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *flightContext) Done() <-chan struct{} { return c.done }
+
+func (c *flightContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
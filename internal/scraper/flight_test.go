@@ -0,0 +1,200 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// startFlight runs g.do for key on ctx in the background and returns a
+// channel delivering its error.
+func startFlight(ctx context.Context, g *flightGroup, key string, fn func(context.Context) internal.ArticleResult) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, key, fn)
+		errc <- err
+	}()
+	return errc
+}
+
+func TestFlightCallerLeavingKeepsOthers(t *testing.T) {
+	var g flightGroup
+	started, release := make(chan context.Context, 1), make(chan struct{})
+	fn := func(ctx context.Context) internal.ArticleResult {
+		started <- ctx
+		<-release
+		return internal.ArticleResult{Content: "shared"}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	errFirst := startFlight(first, &g, "k", fn)
+	flightCtx := <-started
+
+	calls := 0
+	secondDone := make(chan internal.ArticleResult)
+	go func() {
+		r, _ := g.do(context.Background(), "k", func(context.Context) internal.ArticleResult {
+			calls++
+			return internal.ArticleResult{}
+		})
+		secondDone <- r
+	}()
+	time.Sleep(20 * time.Millisecond) // let the second caller join
+
+	cancel()
+	if err := <-errFirst; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller got %v, want %v", err, context.Canceled)
+	}
+	if err := flightCtx.Err(); err != nil {
+		t.Errorf("flight ended with %v while a caller still waits", err)
+	}
+	close(release)
+	if r := <-secondDone; r.Content != "shared" || calls != 0 {
+		t.Errorf("second caller got %q after %d runs of its own fn, want the shared result", r.Content, calls)
+	}
+}
+
+func TestFlightCanceledWhenLastCallerLeaves(t *testing.T) {
+	var g flightGroup
+	started, ended := make(chan struct{}), make(chan error, 1)
+	fn := func(ctx context.Context) internal.ArticleResult {
+		close(started)
+		<-ctx.Done()
+		ended <- ctx.Err()
+		return internal.ArticleResult{}
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	err1 := startFlight(ctx1, &g, "k", fn)
+	<-started
+	err2 := startFlight(ctx2, &g, "k", fn)
+	time.Sleep(20 * time.Millisecond)
+
+	cancel1()
+	<-err1
+	select {
+	case err := <-ended:
+		t.Fatalf("flight ended with %v while a caller still waits", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel2()
+	<-err2
+	select {
+	case err := <-ended:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("flight ended with %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("flight still running after every caller left")
+	}
+
+	// The next caller starts a fresh flight.
+	r, err := g.do(context.Background(), "k", func(context.Context) internal.ArticleResult {
+		return internal.ArticleResult{Content: "fresh"}
+	})
+	if err != nil || r.Content != "fresh" {
+		t.Errorf("got %q, %v, want a fresh flight", r.Content, err)
+	}
+}
+
+func TestFlightLaterCallerExtendsDeadline(t *testing.T) {
+	var g flightGroup
+	started, release, ended := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+	fn := func(ctx context.Context) internal.ArticleResult {
+		close(started)
+		select {
+		case <-ctx.Done():
+			ended <- ctx.Err()
+			return internal.ArticleResult{}
+		case <-release:
+			return internal.ArticleResult{Content: "shared"}
+		}
+	}
+
+	short, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errShort := startFlight(short, &g, "k", fn)
+	<-started
+	long, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	longDone := make(chan internal.ArticleResult, 1)
+	go func() {
+		r, _ := g.do(long, "k", fn)
+		longDone <- r
+	}()
+	for joined := false; !joined; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		joined = g.calls["k"] != nil && g.calls["k"].waiters == 2
+		g.mu.Unlock()
+	}
+
+	if err := <-errShort; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("short caller got %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case err := <-ended:
+		t.Fatalf("flight ended with %v at the first caller's deadline", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if r := <-longDone; r.Content != "shared" {
+		t.Errorf("long caller got %q, want the shared result", r.Content)
+	}
+}
+
+func TestFlightContextDeadline(t *testing.T) {
+	c := newFlightContext(context.Background())
+	defer c.cancel(context.Canceled)
+	near, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	far, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	c.join(far)
+	c.join(near)
+	want, _ := far.Deadline()
+	if d, ok := c.Deadline(); !ok || !d.Equal(want) {
+		t.Errorf("got deadline %v, %v, want the later one %v", d, ok, want)
+	}
+	c.join(context.Background())
+	if _, ok := c.Deadline(); ok {
+		t.Errorf("a caller without a deadline left one in place")
+	}
+}
+
+func TestScrapeNoCacheReplacesEntry(t *testing.T) {
+	cache, err := NewResultCache(time.Hour, 10, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := "first"
+	s := newTestScraper(t, func(ctx context.Context, u string) (Page, error) {
+		return htmlPage("<p>" + version + "</p>"), nil
+	}, WithCache(cache))
+
+	scrape := func(noCache bool) internal.ArticleResult {
+		t.Helper()
+		rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{"https://example.com/a"}, NoCache: noCache})
+		if err != nil || len(rs) != 1 {
+			t.Fatalf("Scrape: %v, %d results", err, len(rs))
+		}
+		return rs[0]
+	}
+	if r := scrape(false); r.Content != "first" || r.Cached {
+		t.Fatalf("got %q, cached %v", r.Content, r.Cached)
+	}
+	version = "second"
+	if r := scrape(false); r.Content != "first" || !r.Cached {
+		t.Errorf("got %q, cached %v, want the cached first version", r.Content, r.Cached)
+	}
+	if r := scrape(true); r.Content != "second" || r.Cached {
+		t.Errorf("no_cache got %q, cached %v, want a fresh second version", r.Content, r.Cached)
+	}
+	if r := scrape(false); r.Content != "second" || !r.Cached {
+		t.Errorf("got %q, cached %v, want the refreshed entry", r.Content, r.Cached)
+	}
+}
@@ -0,0 +1,144 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// reservedPrefixes are ranges not covered by the netip.Addr predicates used
+// in forbiddenAddr that still must not be reachable from a public fetcher.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT, also Alibaba Cloud metadata
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, embeds an IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("fec0::/10"),       // deprecated site-local
+	netip.MustParsePrefix("2002::/16"),       // 6to4, embeds an IPv4 address
+	netip.MustParsePrefix("2001::/32"),       // Teredo, embeds an IPv4 address
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("::/128"),          // unspecified
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+// NetGuard keeps the fetcher away from internal networks. It refuses
+// schemes other than http and https and, after DNS resolution, any
+// loopback, private, link-local (including cloud metadata endpoints),
+// multicast or otherwise reserved address. The check runs on every
+// connection, so redirects and DNS rebinding cannot get around it.
+type NetGuard struct {
+	allowNets  []netip.Prefix
+	allowHosts []string
+
+	transport *http.Transport
+}
+
+// NewNetGuard creates a guard. allow lists exceptions for legitimate
+// intranet use: IP addresses, CIDR ranges, or host names (which also cover
+// their subdomains and are trusted whatever they resolve to).
+func NewNetGuard(allow ...string) *NetGuard {
+	g := &NetGuard{}
+	for _, a := range allow {
+		a = strings.TrimSpace(a)
+		if p, err := netip.ParsePrefix(a); err == nil {
+			g.allowNets = append(g.allowNets, p.Masked())
+		} else if ip, err := netip.ParseAddr(a); err == nil {
+			g.allowNets = append(g.allowNets, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+		} else if a != "" {
+			g.allowHosts = append(g.allowHosts, hostKey(a))
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := &net.Dialer{Timeout: dialer.Timeout, KeepAlive: dialer.KeepAlive, Control: g.control}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the dialer see only the proxy's address.
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && g.allowedHost(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+	g.transport = t
+	return g
+}
+
+// Transport returns a RoundTripper that applies the guard to every request
+// and connection. It is safe to share between clients.
+func (g *NetGuard) Transport() http.RoundTripper {
+	return guardTransport{g.transport}
+}
+
+// guardTransport rejects non-HTTP schemes before they reach the transport,
+// so a redirect to file:// or gopher:// fails with a classified error.
+type guardTransport struct {
+	base http.RoundTripper
+}
+
+func (t guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &Error{
+			Code: internal.ErrForbiddenTarget,
+			Err:  fmt.Errorf("scheme %q is not allowed", req.URL.Scheme),
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// control runs after DNS resolution with the address about to be dialled.
+func (g *NetGuard) control(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &Error{Code: internal.ErrForbiddenTarget, Err: fmt.Errorf("unparseable address %q", address)}
+	}
+	ip := ap.Addr().Unmap()
+	if !forbiddenAddr(ip) {
+		return nil
+	}
+	for _, p := range g.allowNets {
+		if p.Contains(ip) {
+			return nil
+		}
+	}
+	return &Error{Code: internal.ErrForbiddenTarget, Err: fmt.Errorf("address %s is not publicly routable", ip)}
+}
+
+func (g *NetGuard) allowedHost(host string) bool {
+	for _, h := range g.allowHosts {
+		if matchesDomain(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbiddenAddr reports whether ip is not a public unicast address.
+func forbiddenAddr(ip netip.Addr) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
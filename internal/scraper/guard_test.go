@@ -0,0 +1,142 @@
+package scraper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// newServerOn starts an HTML server listening on ip, so a test can tell a
+// "public" server from a private one by its loopback address.
+func newServerOn(t *testing.T, ip string, h http.Handler) *httptest.Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+	s := httptest.NewUnstartedServer(h)
+	s.Listener.Close()
+	s.Listener = ln
+	s.Start()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func guardedFetch(t *testing.T, g *NetGuard, target string) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := NewHTTPFetcher(5*time.Second, WithNetGuard(g)).Fetch(ctx, target)
+	return err
+}
+
+func TestNetGuardRejects(t *testing.T) {
+	private := newServerOn(t, "127.0.0.1", serveBody("text/html", "<p>internal</p>"))
+	port := private.Listener.Addr().(*net.TCPAddr).Port
+	// The redirector stands in for a public host: only its address is allowed.
+	public := newServerOn(t, "127.0.0.2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if to := r.URL.Query().Get("to"); to != "" {
+			http.Redirect(w, r, to, http.StatusFound)
+			return
+		}
+		serveBody("text/html", "<p>public</p>")(w, r)
+	}))
+	g := NewNetGuard("127.0.0.2")
+	if err := guardedFetch(t, g, public.URL+"/?to=/"); err != nil {
+		t.Fatalf("public redirect: %v", err)
+	}
+
+	tests := []struct {
+		name, url string
+	}{
+		{"loopback", private.URL},
+		{"localhost name", strings.Replace(private.URL, "127.0.0.1", "localhost", 1)},
+		{"metadata endpoint", "http://169.254.169.254/latest/meta-data/"},
+		{"IPv4-mapped IPv6 loopback", "http://[::ffff:127.0.0.1]:" + strconv.Itoa(port) + "/"},
+		{"redirect to private address", public.URL + "/?to=" + private.URL},
+		{"redirect to metadata endpoint", public.URL + "/?to=http://169.254.169.254/"},
+		{"file scheme", "file:///etc/passwd"},
+		{"ftp scheme", "ftp://example.com/file"},
+		{"redirect to file scheme", public.URL + "/?to=file:///etc/passwd"},
+	}
+	for _, tt := range tests {
+		err := guardedFetch(t, g, tt.url)
+		if e := classify(err); err == nil || e.Code != internal.ErrForbiddenTarget {
+			t.Errorf("%s: got %v, want %s", tt.name, err, internal.ErrForbiddenTarget)
+		}
+	}
+}
+
+func TestNetGuardAllowlist(t *testing.T) {
+	s := newServerOn(t, "127.0.0.1", serveBody("text/html", "<p>intranet</p>"))
+	byName := strings.Replace(s.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name, allow, url string
+	}{
+		{"address", "127.0.0.1", s.URL},
+		{"CIDR", "127.0.0.0/8", s.URL},
+		{"IPv4-mapped address", "::ffff:127.0.0.1", s.URL},
+		{"host name", "localhost", byName},
+	}
+	for _, tt := range tests {
+		if err := guardedFetch(t, NewNetGuard(tt.allow), tt.url); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestNetGuardAllowedHost(t *testing.T) {
+	g := NewNetGuard("intranet.example", " 10.0.0.0/8 ")
+	for host, want := range map[string]bool{
+		"intranet.example":      true,
+		"wiki.intranet.example": true,
+		"INTRANET.example":      true,
+		"notintranet.example":   false,
+		"intranet.example.com":  false,
+	} {
+		if got := g.allowedHost(host); got != want {
+			t.Errorf("allowedHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+	for _, addr := range []string{"192.168.1.1:80", "[::ffff:169.254.169.254]:80", "[::ffff:10.1.2.3]:80"} {
+		err := g.control("tcp", addr, nil)
+		if want := addr != "[::ffff:10.1.2.3]:80"; (err != nil) != want {
+			t.Errorf("control(%s) = %v, want rejected %v", addr, err, want)
+		}
+	}
+}
+
+func TestForbiddenAddr(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1":              true,
+		"10.0.0.1":               true,
+		"172.16.5.4":             true,
+		"192.168.0.1":            true,
+		"169.254.169.254":        true,
+		"100.100.100.200":        true,
+		"0.0.0.0":                true,
+		"::1":                    true,
+		"fe80::1":                true,
+		"fd00:ec2::254":          true,
+		"::ffff:127.0.0.1":       true,
+		"::ffff:169.254.169.254": true,
+		"64:ff9b::a9fe:a9fe":     true,
+		"93.184.216.34":          false,
+		"2606:4700::6810:84e5":   false,
+	}
+	for s, want := range tests {
+		// control unmaps before checking, as dialled addresses may be mapped.
+		if got := forbiddenAddr(netip.MustParseAddr(s).Unmap()); got != want {
+			t.Errorf("forbiddenAddr(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package scraper
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+// Limits on walking embedded state, which can be megabytes of JSON.
+const (
+	maxStateDepth = 64
+	maxStateNodes = 200_000
+	// minStateBody is the shortest string taken for an article body.
+	minStateBody = 200
+)
+
+// stateAssignRe finds framework state assigned to a window global, e.g.
+// window.__INITIAL_STATE__ = {...} or window.__NUXT__=JSON.parse("...").
+var stateAssignRe = regexp.MustCompile(`window\.(__[A-Za-z0-9_]+)\s*=\s*`)
+
+// Field names that hold article parts across common CMS and framework state shapes.
+var (
+	stateBodyKeys  = []string{"articleBody", "body", "bodyHtml", "body_html", "content", "contentHtml", "content_html", "articleText", "html", "text"}
+	stateTitleKeys = []string{"headline", "title", "seoTitle"}
+	stateDateKeys  = []string{"datePublished", "publishedAt", "published_at", "publishDate", "publishedDate", "firstPublished", "first_published_at", "date"}
+	// stateBlockKeys hold lists of body blocks (paragraphs, rich-text nodes).
+	stateBlockKeys = []string{"children", "content", "blocks", "paragraphs", "items", "elements"}
+	// stateTextKeys hold the text of a single block.
+	stateTextKeys = []string{"text", "html", "value", "content"}
+)
+
+// stateArticle is an article recovered from embedded state.
+type stateArticle struct {
+	title string
+	body  string // plain text with one paragraph per line, or HTML
+	date  string // RFC 3339
+}
+
+// embeddedState finds the article in the JSON state that JS-rendered pages
+// embed for hydration: Next.js __NEXT_DATA__, Nuxt payloads, other
+// application/json scripts and window.__*__ assignments. It picks the
+// object with the longest body. It reads scripts from the page as fetched:
+// readability's article no longer has them, and a site rule may remove them.
+func embeddedState(doc *html.Node) stateArticle {
+	w := &stateWalker{}
+	for _, script := range dom.GetElementsByTagName(doc, "script") {
+		text := dom.TextContent(script)
+		switch {
+		case strings.EqualFold(dom.GetAttribute(script, "type"), "application/json"):
+			var v any
+			if json.Unmarshal([]byte(text), &v) != nil {
+				continue
+			}
+			if dom.GetAttribute(script, "id") == "__NUXT_DATA__" {
+				v = reviveNuxt(v)
+			}
+			w.walk(v, 0)
+		case dom.GetAttribute(script, "src") == "":
+			for _, loc := range stateAssignRe.FindAllStringIndex(text, -1) {
+				if v, ok := decodeAssigned(text[loc[1]:]); ok {
+					w.walk(v, 0)
+				}
+			}
+		}
+	}
+	return w.best
+}
+
+// decodeAssigned decodes the JSON value at the start of s, which is either
+// a literal or a JSON.parse("...") call. JavaScript that is not JSON fails.
+func decodeAssigned(s string) (any, bool) {
+	if rest, ok := strings.CutPrefix(s, "JSON.parse("); ok {
+		var inner string
+		if json.NewDecoder(strings.NewReader(rest)).Decode(&inner) != nil {
+			return nil, false
+		}
+		s = inner
+	}
+	var v any
+	if json.NewDecoder(strings.NewReader(s)).Decode(&v) != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+type stateWalker struct {
+	best  stateArticle
+	bodyN int // runes in best.body
+	nodes int
+}
+
+func (w *stateWalker) walk(v any, depth int) {
+	w.nodes++
+	if depth > maxStateDepth || w.nodes > maxStateNodes {
+		return
+	}
+	switch t := v.(type) {
+	case []any:
+		for _, item := range t {
+			w.walk(item, depth+1)
+		}
+	case map[string]any:
+		if body := stateBody(t); utf8.RuneCountInString(body) > max(w.bodyN, minStateBody) {
+			w.best = stateArticle{body: body, title: stateTitle(t), date: stateDate(t)}
+			w.bodyN = utf8.RuneCountInString(body)
+		}
+		for _, child := range t {
+			w.walk(child, depth+1)
+		}
+	}
+}
+
+// stateBody returns the longest body-like field of obj.
+func stateBody(obj map[string]any) string {
+	best := ""
+	for _, k := range stateBodyKeys {
+		var paras []string
+		switch v := obj[k].(type) {
+		case string:
+			paras = []string{v}
+		case []any, map[string]any:
+			paras = blockText(v, 0)
+		}
+		if body := joinParagraphs(paras); len(body) > len(best) {
+			best = body
+		}
+	}
+	return best
+}
+
+// blockText flattens rich-text block structures into paragraphs.
+func blockText(v any, depth int) []string {
+	if depth > maxStateDepth {
+		return nil
+	}
+	switch t := v.(type) {
+	case string:
+		if s := strings.TrimSpace(t); s != "" {
+			return []string{s}
+		}
+	case []any:
+		var out []string
+		for _, item := range t {
+			out = append(out, blockText(item, depth+1)...)
+		}
+		return out
+	case map[string]any:
+		for _, k := range stateTextKeys {
+			if s, ok := t[k].(string); ok && strings.TrimSpace(s) != "" {
+				return []string{strings.TrimSpace(s)}
+			}
+		}
+		for _, k := range stateBlockKeys {
+			if child, ok := t[k]; ok {
+				if out := blockText(child, depth+1); len(out) > 0 {
+					return out
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// joinParagraphs joins plain paragraphs with newlines. If any paragraph is
+// HTML, plain ones are wrapped in <p> so that the result parses as HTML.
+func joinParagraphs(paras []string) string {
+	hasHTML := false
+	for _, p := range paras {
+		if strings.Contains(p, "<") {
+			hasHTML = true
+			break
+		}
+	}
+	if !hasHTML {
+		return strings.Join(paras, "\n")
+	}
+	var b strings.Builder
+	for _, p := range paras {
+		if strings.Contains(p, "<") {
+			b.WriteString(p)
+		} else {
+			b.WriteString("<p>" + html.EscapeString(p) + "</p>")
+		}
+	}
+	return b.String()
+}
+
+func stateTitle(obj map[string]any) string {
+	for _, k := range stateTitleKeys {
+		if s, ok := obj[k].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+func stateDate(obj map[string]any) string {
+	var values []string
+	for _, k := range stateDateKeys {
+		switch v := obj[k].(type) {
+		case string:
+			values = append(values, v)
+		case float64:
+			values = append(values, strconv.FormatInt(int64(v), 10))
+		}
+	}
+	return firstDate(values...)
+}
+
+// reviveNuxt rebuilds the value tree of a Nuxt 3 __NUXT_DATA__ payload, a
+// flat array in which containers refer to their members by index.
+func reviveNuxt(v any) any {
+	arr, ok := v.([]any)
+	if !ok || len(arr) == 0 {
+		return v
+	}
+	nodes := 0
+	var revive func(i, depth int) any
+	revive = func(i, depth int) any {
+		nodes++
+		if i < 0 || i >= len(arr) || depth > maxStateDepth || nodes > maxStateNodes {
+			return nil
+		}
+		ref := func(x any) any {
+			if f, ok := x.(float64); ok {
+				return revive(int(f), depth+1)
+			}
+			return nil
+		}
+		switch t := arr[i].(type) {
+		case map[string]any:
+			out := make(map[string]any, len(t))
+			for k, x := range t {
+				out[k] = ref(x)
+			}
+			return out
+		case []any:
+			// Typed wrappers look like ["Reactive", 3] or ["Date", "2025-01-01T..."].
+			if len(t) == 2 {
+				switch tag, _ := t[0].(string); tag {
+				case "Reactive", "ShallowReactive", "Ref", "ShallowRef", "EmptyRef", "EmptyShallowRef", "NuxtError", "Island":
+					return ref(t[1])
+				case "Date":
+					return t[1]
+				}
+			}
+			out := make([]any, 0, len(t))
+			for _, x := range t {
+				out = append(out, ref(x))
+			}
+			return out
+		default:
+			return t
+		}
+	}
+	return revive(0, 0)
+}
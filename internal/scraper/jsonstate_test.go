@@ -0,0 +1,169 @@
+package scraper
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// stateBodyText is long enough to be taken for an article body.
+var stateBodyText = strings.Repeat("The council approved the budget after a long debate. ", 5)
+
+func stateJSON(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestEmbeddedState(t *testing.T) {
+	article := map[string]any{
+		"headline":    "Budget approved",
+		"articleBody": stateBodyText,
+		"publishedAt": "2024-03-05T10:00:00Z",
+	}
+	articleJSON := stateJSON(t, map[string]any{"props": map[string]any{"pageProps": map[string]any{"article": article}}})
+	nuxt := stateJSON(t, []any{
+		[]any{"ShallowReactive", 1},
+		map[string]any{"data": 2, "state": 7},
+		map[string]any{"story": 3},
+		map[string]any{"title": 4, "content": 5, "date": 6},
+		"Budget approved",
+		stateBodyText,
+		[]any{"Date", "2024-03-05T10:00:00Z"},
+		map[string]any{"teaser": 8},
+		"Short teaser.",
+	})
+	blocks := stateJSON(t, map[string]any{"post": map[string]any{
+		"title":              "Budget approved",
+		"first_published_at": 1709632800,
+		"content": []any{
+			map[string]any{"type": "paragraph", "text": stateBodyText},
+			map[string]any{"type": "paragraph", "children": []any{map[string]any{"value": "Second paragraph."}}},
+		},
+	}})
+
+	tests := []struct {
+		name, script, body string
+	}{
+		{"__NEXT_DATA__", `<script id="__NEXT_DATA__" type="application/json">` + articleJSON + `</script>`, stateBodyText},
+		{"window assignment", `<script>var a = 1; window.__INITIAL_STATE__ = ` + articleJSON + `; init();</script>`, stateBodyText},
+		{"JSON.parse assignment", `<script>window.__APOLLO_STATE__=JSON.parse(` + stateJSON(t, articleJSON) + `)</script>`, stateBodyText},
+		{"Nuxt 3 payload", `<script type="application/json" id="__NUXT_DATA__">` + nuxt + `</script>`, stateBodyText},
+		{"rich-text blocks", `<script type="application/json">` + blocks + `</script>`, strings.TrimSpace(stateBodyText) + "\nSecond paragraph."},
+	}
+	for _, tt := range tests {
+		doc := parseTestHTML(t, "<html><head>"+tt.script+"</head><body></body></html>")
+		got := embeddedState(doc)
+		if got.body != tt.body || got.title != "Budget approved" || !strings.HasPrefix(got.date, "2024-03-05T10:00:00Z") {
+			t.Errorf("%s: got title %q, date %q, body %q", tt.name, got.title, got.date, got.body)
+		}
+	}
+}
+
+func TestEmbeddedStateIgnores(t *testing.T) {
+	tests := []struct {
+		name, script string
+	}{
+		{"short body", `<script type="application/json">{"body": "Too short to be an article."}</script>`},
+		{"invalid JSON", `<script type="application/json">{"body": "` + stateBodyText + `"</script>`},
+		{"JavaScript object", `<script>window.__STATE__ = {body: "` + stateBodyText + `"};</script>`},
+		{"external script", `<script src="/app.js">window.__STATE__ = {"body": "` + stateBodyText + `"}</script>`},
+	}
+	for _, tt := range tests {
+		doc := parseTestHTML(t, "<html><head>"+tt.script+"</head><body></body></html>")
+		if got := embeddedState(doc); got.body != "" {
+			t.Errorf("%s: got body %q", tt.name, got.body)
+		}
+	}
+}
+
+func TestDecodeAssigned(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`{"a": [1, "x"]}; window.b = 2;`, `{"a":[1,"x"]}`, true},
+		{`JSON.parse("{\"a\":1}");`, `{"a":1}`, true},
+		{`JSON.parse('{"a":1}')`, "", false},
+		{`JSON.parse("not json")`, "", false},
+		{`{a: 1}`, "", false},
+		{`"text"`, `"text"`, true},
+	}
+	for _, tt := range tests {
+		v, ok := decodeAssigned(tt.in)
+		if ok != tt.ok || (ok && stateJSON(t, v) != tt.want) {
+			t.Errorf("decodeAssigned(%q) = %v, %v, want %s, %v", tt.in, v, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReviveNuxt(t *testing.T) {
+	var payload any
+	if err := json.Unmarshal([]byte(`[
+		["Reactive", 1],
+		{"list": 2, "when": 4, "self": 0, "bad": 99, "literal": "x"},
+		[3, 3],
+		"shared",
+		["Date", "2024-03-05"]
+	]`), &payload); err != nil {
+		t.Fatal(err)
+	}
+	got := stateJSON(t, reviveNuxt(payload))
+	// "self" refers back to the root and is cut off at the depth limit;
+	// out-of-range and non-index members revive to null.
+	for _, want := range []string{`"list":["shared","shared"]`, `"when":"2024-03-05"`, `"bad":null`, `"literal":null`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("revived %s, want it to contain %s", got, want)
+		}
+	}
+	if n := strings.Count(got, `"self"`); n < 2 || n > maxStateDepth {
+		t.Errorf("self reference revived %d levels deep", n)
+	}
+
+	for _, v := range []any{nil, "x", []any{}, map[string]any{"a": 1.0}} {
+		if got := reviveNuxt(v); stateJSON(t, got) != stateJSON(t, v) {
+			t.Errorf("reviveNuxt(%v) = %v, want it unchanged", v, got)
+		}
+	}
+}
+
+func TestEmbeddedStateLimits(t *testing.T) {
+	article := `{"body": "` + stateBodyText + `"}`
+
+	// An article nested deeper than maxStateDepth is not reached.
+	deep := strings.Repeat("[", maxStateDepth+2) + article + strings.Repeat("]", maxStateDepth+2)
+	// One past maxStateNodes other values comes before the article.
+	wide := "[" + strings.Repeat("0,", maxStateNodes) + article + "]"
+	// A Nuxt payload whose entries each refer to the next one twice fans out
+	// exponentially; the article, revived after it, is past the node limit.
+	fanout := []any{[]any{1, 41}}
+	for i := 1; i < 40; i++ {
+		fanout = append(fanout, []any{i + 1, i + 1})
+	}
+	fanout = append(fanout, "x", map[string]any{"body": 42}, stateBodyText)
+
+	tests := []struct {
+		name, script string
+	}{
+		{"too deep", `<script type="application/json">` + deep + `</script>`},
+		{"too many nodes", `<script type="application/json">` + wide + `</script>`},
+		{"Nuxt fan-out", `<script type="application/json" id="__NUXT_DATA__">` + stateJSON(t, fanout) + `</script>`},
+	}
+	for _, tt := range tests {
+		doc := parseTestHTML(t, "<html><head>"+tt.script+"</head><body></body></html>")
+		if got := embeddedState(doc); got.body != "" {
+			t.Errorf("%s: found body %.40q past the limits", tt.name, got.body)
+		}
+	}
+
+	// Just inside the depth limit the article is still found.
+	ok := strings.Repeat("[", maxStateDepth-1) + article + strings.Repeat("]", maxStateDepth-1)
+	doc := parseTestHTML(t, `<html><head><script type="application/json">`+ok+`</script></head></html>`)
+	if got := embeddedState(doc); got.body != stateBodyText {
+		t.Errorf("article at depth %d not found", maxStateDepth)
+	}
+}
@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit caps how hard a single host is hit. Zero values disable the
+// respective constraint.
+type HostLimit struct {
+	MaxConcurrency int           // simultaneous requests to the host
+	MinDelay       time.Duration // minimum gap between request starts
+}
+
+// maxIdleHosts is the number of tracked hosts above which idle entries are pruned.
+const maxIdleHosts = 1024
+
+// HostLimiter enforces per-host politeness across all fetches in the process.
+// Overrides are keyed by domain and apply to the domain and its subdomains;
+// all hosts matching one override share a single budget.
+type HostLimiter struct {
+	def       HostLimit
+	overrides map[string]HostLimit
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	active int
+	next   time.Time     // earliest start time for the next request
+	freed  chan struct{} // closed and replaced whenever a slot is released
+}
+
+// NewHostLimiter creates a HostLimiter with a default limit and per-domain overrides.
+func NewHostLimiter(def HostLimit, overrides map[string]HostLimit) *HostLimiter {
+	normalized := make(map[string]HostLimit, len(overrides))
+	for domain, limit := range overrides {
+		normalized[hostKey(domain)] = limit
+	}
+	return &HostLimiter{
+		def:       def,
+		overrides: normalized,
+		hosts:     make(map[string]*hostState),
+	}
+}
+
+// Acquire blocks until a request to host may start. It returns how long the
+// caller waited and a release func that must be called once the request is done.
+// If the host's minimum delay would push the start past ctx's deadline, it
+// fails at once with a retryable timeout instead of waiting.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (time.Duration, func(), error) {
+	start := time.Now()
+	key, limit := l.lookup(host)
+
+	for {
+		l.mu.Lock()
+		st := l.state(key)
+		now := time.Now()
+		full := limit.MaxConcurrency > 0 && st.active >= limit.MaxConcurrency
+		if !full && !now.Before(st.next) {
+			st.active++
+			st.next = now.Add(limit.MinDelay)
+			l.mu.Unlock()
+			var once sync.Once
+			return time.Since(start), func() { once.Do(func() { l.release(st) }) }, nil
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && st.next.After(deadline) {
+			l.mu.Unlock()
+			return time.Since(start), nil, errPastDeadline(host)
+		}
+
+		var timer *time.Timer
+		var wake <-chan time.Time
+		freed := st.freed
+		if !full {
+			timer = time.NewTimer(st.next.Sub(now))
+			wake = timer.C
+			freed = nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return time.Since(start), nil, ctx.Err()
+		case <-freed:
+		case <-wake:
+		}
+	}
+}
+
+func (l *HostLimiter) release(st *hostState) {
+	l.mu.Lock()
+	st.active--
+	close(st.freed)
+	st.freed = make(chan struct{})
+	l.mu.Unlock()
+}
+
+// lookup resolves host to its limiter key and effective limit.
+func (l *HostLimiter) lookup(host string) (string, HostLimit) {
+	key := hostKey(host)
+	for domain := key; domain != ""; {
+		if limit, ok := l.overrides[domain]; ok {
+			return domain, limit
+		}
+		_, rest, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = rest
+	}
+	return key, l.def
+}
+
+// state returns the state for key, creating it if needed. Must hold l.mu.
+func (l *HostLimiter) state(key string) *hostState {
+	if st, ok := l.hosts[key]; ok {
+		return st
+	}
+	if len(l.hosts) >= maxIdleHosts {
+		now := time.Now()
+		for k, st := range l.hosts {
+			if st.active == 0 && now.After(st.next) {
+				delete(l.hosts, k)
+			}
+		}
+	}
+	st := &hostState{freed: make(chan struct{})}
+	l.hosts[key] = st
+	return st
+}
+
+// hostKey lowercases a hostname and strips a leading "www.".
+func hostKey(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
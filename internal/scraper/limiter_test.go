@@ -0,0 +1,29 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestHostLimiterPastDeadline(t *testing.T) {
+	l := NewHostLimiter(HostLimit{MinDelay: time.Hour}, nil)
+	_, release, err := l.Acquire(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, _, err = l.Acquire(ctx, "www.example.com")
+	if e := classify(err); e.Code != internal.ErrTimeout || !e.Retryable {
+		t.Errorf("got %v, want a retryable timeout", err)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("waited %v before giving up", waited)
+	}
+}
@@ -0,0 +1,310 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+// metadata is article information found outside the body text: JSON-LD,
+// OpenGraph, Twitter cards and plain meta tags.
+type metadata struct {
+	Published string // RFC 3339
+	Modified  string // RFC 3339
+	Language  string
+	Image     string // absolute URL
+	Canonical string // absolute URL
+	Keywords  []string
+	Section   string
+
+	Body        string // JSON-LD articleBody
+	Description string // summary from JSON-LD, OpenGraph or meta description
+	Locked      string // why the article is marked as not free, empty if it is not
+}
+
+// articleTypeRe matches schema.org types that describe an article.
+var articleTypeRe = regexp.MustCompile(`(?i)^(Article|NewsArticle|AnalysisNewsArticle|OpinionNewsArticle|ReportageNewsArticle|BackgroundNewsArticle|ReviewNewsArticle|BlogPosting|LiveBlogPosting|Report|ScholarlyArticle|TechArticle|SocialMediaPosting)$`)
+
+// extractMetadata reads metadata from doc. Sources are consulted from most
+// to least specific: JSON-LD, OpenGraph/article tags, Twitter cards, then
+// generic meta tags. It must run before a site rule's removals, which
+// change doc.
+func extractMetadata(doc *html.Node, base *url.URL) metadata {
+	ld := jsonLDArticle(doc)
+	meta := metaTags(doc)
+
+	var m metadata
+	m.Published = firstDate(
+		ldString(ld["datePublished"]), meta["article:published_time"],
+		meta["og:published_time"], meta["datepublished"], meta["pubdate"],
+		meta["publishdate"], meta["date"], meta["dc.date.issued"], meta["dc.date"],
+		meta["parsely-pub-date"], meta["sailthru.date"], timeDatetime(doc),
+	)
+	m.Modified = firstDate(
+		ldString(ld["dateModified"]), meta["article:modified_time"],
+		meta["og:updated_time"], meta["datemodified"], meta["last-modified"],
+	)
+	m.Language = normalizeLanguage(firstNonEmpty(
+		ldString(ld["inLanguage"]), htmlLang(doc), meta["content-language"],
+		meta["og:locale"], meta["language"],
+	))
+	m.Image = resolveURL(base, firstNonEmpty(
+		ldImage(ld["image"]), meta["og:image"], meta["og:image:url"],
+		meta["og:image:secure_url"], meta["twitter:image"], meta["twitter:image:src"],
+	))
+	m.Canonical = resolveURL(base, firstNonEmpty(
+		linkHref(doc, "canonical"), meta["og:url"], ldID(ld["mainEntityOfPage"]), ldString(ld["url"]),
+	))
+	m.Section = firstNonEmpty(ldString(ld["articleSection"]), meta["article:section"], meta["section"])
+	m.Body = ldString(ld["articleBody"])
+	m.Description = firstNonEmpty(
+		meta["og:description"], ldString(ld["description"]), meta["twitter:description"], meta["description"],
+	)
+	m.Locked = locked(ld, meta)
+
+	if kw := ldStrings(ld["keywords"]); len(kw) > 1 {
+		m.Keywords = kw
+	} else if len(kw) == 1 {
+		m.Keywords = splitKeywords(kw[0])
+	} else if tags := metaAll(doc, "article:tag"); len(tags) > 0 {
+		m.Keywords = tags
+	} else {
+		m.Keywords = splitKeywords(firstNonEmpty(meta["news_keywords"], meta["keywords"]))
+	}
+	return m
+}
+
+// locked reports why the article is marked as paid: schema.org
+// isAccessibleForFree on the article or one of its parts, or a locked or
+// metered content tier.
+func locked(ld map[string]any, meta map[string]string) string {
+	free := []any{ld["isAccessibleForFree"], meta["isaccessibleforfree"]}
+	for _, part := range ldObjects(ld["hasPart"]) {
+		free = append(free, part["isAccessibleForFree"])
+	}
+	for _, v := range free {
+		if b, ok := v.(bool); (ok && !b) || strings.EqualFold(ldString(v), "false") {
+			return "isAccessibleForFree is false"
+		}
+	}
+	switch tier := strings.ToLower(meta["article:content_tier"]); tier {
+	case "locked", "metered":
+		return "content tier is " + tier
+	}
+	return ""
+}
+
+// jsonLDArticle returns the first article-like object in the document's
+// JSON-LD blocks, searching top-level arrays and @graph containers.
+func jsonLDArticle(doc *html.Node) map[string]any {
+	var fallback map[string]any
+	for _, script := range dom.QuerySelectorAll(doc, `script[type="application/ld+json"]`) {
+		var v any
+		if err := json.Unmarshal([]byte(dom.TextContent(script)), &v); err != nil {
+			continue
+		}
+		for _, obj := range ldObjects(v) {
+			for _, t := range ldStrings(obj["@type"]) {
+				if articleTypeRe.MatchString(t) {
+					return obj
+				}
+				if t == "WebPage" && fallback == nil {
+					fallback = obj
+				}
+			}
+		}
+	}
+	return fallback
+}
+
+// ldObjects flattens a JSON-LD value into its objects, expanding arrays and @graph.
+func ldObjects(v any) []map[string]any {
+	switch t := v.(type) {
+	case []any:
+		var out []map[string]any
+		for _, item := range t {
+			out = append(out, ldObjects(item)...)
+		}
+		return out
+	case map[string]any:
+		out := []map[string]any{t}
+		if g, ok := t["@graph"]; ok {
+			out = append(out, ldObjects(g)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// ldString returns v as a string, taking the first element of arrays.
+func ldString(v any) string {
+	if s := ldStrings(v); len(s) > 0 {
+		return s[0]
+	}
+	return ""
+}
+
+func ldStrings(v any) []string {
+	switch t := v.(type) {
+	case string:
+		if t = strings.TrimSpace(t); t != "" {
+			return []string{t}
+		}
+	case []any:
+		var out []string
+		for _, item := range t {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// ldImage handles the string, ImageObject and array forms of schema.org image.
+func ldImage(v any) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []any:
+		if len(t) > 0 {
+			return ldImage(t[0])
+		}
+	case map[string]any:
+		return firstNonEmpty(ldString(t["url"]), ldString(t["contentUrl"]))
+	}
+	return ""
+}
+
+// ldID handles the string and {"@id": ...} forms of a schema.org reference.
+func ldID(v any) string {
+	if m, ok := v.(map[string]any); ok {
+		return ldString(m["@id"])
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// metaTags collects <meta> values keyed by lowercased property, name or
+// http-equiv. The first occurrence of a key wins.
+func metaTags(doc *html.Node) map[string]string {
+	out := make(map[string]string)
+	for _, n := range dom.GetElementsByTagName(doc, "meta") {
+		content := strings.TrimSpace(dom.GetAttribute(n, "content"))
+		if content == "" {
+			continue
+		}
+		for _, key := range []string{"property", "name", "itemprop", "http-equiv"} {
+			k := strings.ToLower(strings.TrimSpace(dom.GetAttribute(n, key)))
+			if k == "" {
+				continue
+			}
+			if _, seen := out[k]; !seen {
+				out[k] = content
+			}
+		}
+	}
+	return out
+}
+
+// metaAll returns every value of a repeatable meta property such as article:tag.
+func metaAll(doc *html.Node, property string) []string {
+	var out []string
+	for _, n := range dom.GetElementsByTagName(doc, "meta") {
+		if strings.EqualFold(dom.GetAttribute(n, "property"), property) {
+			if v := strings.TrimSpace(dom.GetAttribute(n, "content")); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+func linkHref(doc *html.Node, rel string) string {
+	for _, n := range dom.GetElementsByTagName(doc, "link") {
+		if strings.EqualFold(strings.TrimSpace(dom.GetAttribute(n, "rel")), rel) {
+			return strings.TrimSpace(dom.GetAttribute(n, "href"))
+		}
+	}
+	return ""
+}
+
+func htmlLang(doc *html.Node) string {
+	if root := dom.QuerySelector(doc, "html"); root != nil {
+		return dom.GetAttribute(root, "lang")
+	}
+	return ""
+}
+
+// timeDatetime returns the datetime of the first <time> marked as the publication date.
+func timeDatetime(doc *html.Node) string {
+	if n := dom.QuerySelector(doc, `time[itemprop="datePublished"], time[pubdate], article time[datetime]`); n != nil {
+		return dom.GetAttribute(n, "datetime")
+	}
+	return ""
+}
+
+// firstDate returns the first value that parses as a date, formatted as
+// RFC 3339. Dates without a zone are taken as UTC.
+func firstDate(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if t, err := dateparse.ParseIn(v, time.UTC); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// normalizeLanguage turns values like "uk_UA", "en-us" or "zh-hant-tw"
+// into canonical BCP 47 form ("uk-UA", "en-US", "zh-Hant-TW"). Values that
+// are not language tags give "".
+func normalizeLanguage(s string) string {
+	tag, err := language.Parse(strings.TrimSpace(strings.ReplaceAll(s, "_", "-")))
+	if err != nil || tag == language.Und {
+		return ""
+	}
+	return tag.String()
+}
+
+func splitKeywords(s string) []string {
+	var out []string
+	for _, kw := range strings.Split(s, ",") {
+		if kw = strings.TrimSpace(kw); kw != "" {
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
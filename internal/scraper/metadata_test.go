@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":          "en",
+		"EN":          "en",
+		"en-us":       "en-US",
+		"uk_UA":       "uk-UA",
+		" de-de ":     "de-DE",
+		"zh-Hant-TW":  "zh-Hant-TW",
+		"zh-hant-tw":  "zh-Hant-TW",
+		"sr-LATN":     "sr-Latn",
+		"es-419":      "es-419",
+		"de-DE-1996":  "de-DE-1996",
+		"":            "",
+		"und":         "",
+		"English":     "",
+		"en-US-x-bad": "en-US-x-bad",
+	}
+	for in, want := range tests {
+		if got := normalizeLanguage(in); got != want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFirstDate(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"2024-03-05T10:20:30+02:00"}, "2024-03-05T10:20:30+02:00"},
+		{[]string{"2024-03-05T10:20:30Z"}, "2024-03-05T10:20:30Z"},
+		{[]string{"2024-03-05"}, "2024-03-05T00:00:00Z"},
+		{[]string{"2024-03-05 10:20:30"}, "2024-03-05T10:20:30Z"},
+		{[]string{"Tue, 05 Mar 2024 10:20:30 GMT"}, "2024-03-05T10:20:30Z"},
+		{[]string{"March 5, 2024"}, "2024-03-05T00:00:00Z"},
+		{[]string{"", "  ", "not a date", "2024-03-05"}, "2024-03-05T00:00:00Z"},
+		{[]string{"yesterday"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := firstDate(tt.values...); got != tt.want {
+			t.Errorf("firstDate(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestExtractMetadataJSONLD(t *testing.T) {
+	doc := parseTestHTML(t, `<html lang="en"><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+	{"@type": "WebSite", "name": "Site", "datePublished": "2000-01-01"},
+	{"@type": ["NewsArticle"], "datePublished": "2024-03-05T10:20:30+01:00", "dateModified": "2024-03-06 08:00",
+	 "inLanguage": "zh_hant_tw", "image": {"url": "/img/a.jpg"}, "keywords": ["go", "web"],
+	 "mainEntityOfPage": {"@id": "https://example.com/news/a"}, "articleSection": "Tech"}
+]}</script>
+<meta property="article:published_time" content="1999-01-01T00:00:00Z">
+<meta property="og:image" content="https://cdn.example.com/og.jpg">
+</head><body></body></html>`)
+	m := extractMetadata(doc, mustParse(t, "https://example.com/news/a?ref=x"))
+
+	if m.Published != "2024-03-05T10:20:30+01:00" || m.Modified != "2024-03-06T08:00:00Z" {
+		t.Errorf("got published %q, modified %q", m.Published, m.Modified)
+	}
+	if m.Language != "zh-Hant-TW" {
+		t.Errorf("got language %q", m.Language)
+	}
+	if m.Image != "https://example.com/img/a.jpg" || m.Canonical != "https://example.com/news/a" {
+		t.Errorf("got image %q, canonical %q", m.Image, m.Canonical)
+	}
+	if m.Section != "Tech" || !slices.Equal(m.Keywords, []string{"go", "web"}) {
+		t.Errorf("got section %q, keywords %q", m.Section, m.Keywords)
+	}
+}
+
+func TestExtractMetadataOpenGraph(t *testing.T) {
+	doc := parseTestHTML(t, `<html><head>
+<meta property="article:published_time" content="2024-03-05T10:20:30.000Z">
+<meta property="og:updated_time" content="1709720400">
+<meta property="og:locale" content="sr_LATN_RS">
+<meta name="date" content="2000-01-01">
+<meta property="article:tag" content="go"><meta property="article:tag" content="web">
+<link rel="canonical" href="/news/a">
+</head><body><time pubdate datetime="2001-01-01">old</time></body></html>`)
+	m := extractMetadata(doc, mustParse(t, "https://example.com/news/a?ref=x"))
+
+	if m.Published != "2024-03-05T10:20:30Z" || m.Modified != "2024-03-06T10:20:00Z" {
+		t.Errorf("got published %q, modified %q", m.Published, m.Modified)
+	}
+	if m.Language != "sr-Latn-RS" || m.Canonical != "https://example.com/news/a" {
+		t.Errorf("got language %q, canonical %q", m.Language, m.Canonical)
+	}
+	if !slices.Equal(m.Keywords, []string{"go", "web"}) {
+		t.Errorf("got keywords %q", m.Keywords)
+	}
+
+	// Without article tags the publication date falls back to <time>.
+	doc = parseTestHTML(t, `<html><body><article><time datetime="2024-01-02T03:04:05-05:00">Jan 2</time></article></body></html>`)
+	if m := extractMetadata(doc, mustParse(t, "https://example.com/")); m.Published != "2024-01-02T03:04:05-05:00" {
+		t.Errorf("got published %q from <time>", m.Published)
+	}
+}
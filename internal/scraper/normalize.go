@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultTrackingParams are query parameters stripped by a Normalizer when
+// no list is configured. A trailing "*" matches any suffix.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "ocid"}
+
+// Normalizer rewrites URLs into a canonical form so that trivially
+// different links to one article are treated as the same URL.
+type Normalizer struct {
+	exact    map[string]bool
+	prefixes []string
+}
+
+// NewNormalizer creates a Normalizer that strips the given tracking
+// parameters (case-insensitive; "prefix*" patterns allowed).
+func NewNormalizer(trackingParams []string) *Normalizer {
+	n := &Normalizer{exact: make(map[string]bool)}
+	for _, p := range trackingParams {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			n.prefixes = append(n.prefixes, prefix)
+		} else if p != "" {
+			n.exact[p] = true
+		}
+	}
+	return n
+}
+
+// Normalize lowercases the scheme and host, drops default ports, the
+// fragment and tracking parameters, and turns an empty path into "/". The
+// order of the remaining parameters is preserved. Strings that are not
+// absolute http(s) URLs are returned trimmed but otherwise unchanged.
+func (n *Normalizer) Normalize(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return raw
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = n.stripQuery(u.RawQuery)
+	u.ForceQuery = false
+	return u.String()
+}
+
+// stripQuery removes tracking parameters from a raw query string without
+// re-encoding or reordering the rest.
+func (n *Normalizer) stripQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var kept []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if k, err := url.QueryUnescape(key); err == nil {
+			key = k
+		}
+		if !n.tracking(strings.ToLower(key)) {
+			kept = append(kept, pair)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func (n *Normalizer) tracking(key string) bool {
+	if n.exact[key] {
+		return true
+	}
+	for _, p := range n.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package scraper
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	n := NewNormalizer(DefaultTrackingParams)
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTPS://Example.COM:443/a?b=1#top", "https://example.com/a?b=1"},
+		{"http://example.com:80", "http://example.com/"},
+		{"http://example.com:8080/a", "http://example.com:8080/a"},
+		{"https://[2001:DB8::1]:443/a", "https://[2001:db8::1]/a"},
+		{"https://example.com/a?", "https://example.com/a"},
+		{"https://example.com/a?z=1&utm_source=x&a=2&fbclid=y", "https://example.com/a?z=1&a=2"},
+		{"https://example.com/a?q=a%20b&UTM_Medium=x", "https://example.com/a?q=a%20b"},
+		{"https://example.com/a?utm%5Fcampaign=x&ocid=1&gclid=2", "https://example.com/a"},
+		{"  https://example.com/Path  ", "https://example.com/Path"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+		{"ftp://Example.com/a", "ftp://Example.com/a"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTrackingParams(t *testing.T) {
+	// As configured through TRACKING_PARAMS.
+	n := NewNormalizer([]string{" mc_* ", "REF", "", "*_src"})
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/?mc_cid=1&mc_eid=2&id=3", "https://example.com/?id=3"},
+		{"https://example.com/?ref=feed&Ref=x&referrer=y", "https://example.com/?referrer=y"},
+		{"https://example.com/?utm_source=x", "https://example.com/?utm_source=x"},
+		// "*" is only a suffix glob, so "*_src" matches the literal prefix "*_src".
+		{"https://example.com/?a_src=1", "https://example.com/?a_src=1"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/val/autoga/internal"
+)
+
+// pageParams are query parameters that number the pages of an article.
+var pageParams = []string{"page", "p", "pg"}
+
+// pagePathRe matches a trailing page number in a path: /page/2 or /2.
+var pagePathRe = regexp.MustCompile(`(/page)?/(\d{1,3})/?$`)
+
+// pagerTextRe matches blocks that only name a page or a direction.
+var pagerTextRe = regexp.MustCompile(`(?i)^(page \d{1,3}( of \d{1,3})?|next( page)?|previous( page)?|prev|[«‹»›←→<>])$`)
+
+// maxPagerLen bounds the length of a block taken for a page-number list.
+const maxPagerLen = 80
+
+// WithPagination makes the Scraper follow next-page links of articles split
+// across several pages and merge up to maxPages pages into one Content.
+// Values below 2 disable it.
+func WithPagination(maxPages int) Option {
+	return func(s *Scraper) { s.maxPages = maxPages }
+}
+
+// stitchPages appends the following pages of first, an article extracted
+// as HTML from body, page by page until no next page is found or maxPages
+// is reached, and renders the merged article in format. Blocks already
+// seen on an earlier page, such as author boxes and share prompts, and
+// pager links are dropped. Without a next page first is returned as is.
+func (s *Scraper) stitchPages(ctx context.Context, first internal.ArticleResult, body []byte, format internal.Format) internal.ArticleResult {
+	base, err := url.Parse(firstNonEmpty(first.FinalURL, first.URL))
+	if err != nil || first.Format != internal.FormatHTML {
+		return first
+	}
+	next := nextPageURL(body, base, 1)
+	if next == "" {
+		return first
+	}
+	merged := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	seen := make(map[string]bool)
+	appendBlocks(merged, first.Content, seen)
+
+	visited := map[string]bool{first.URL: true, base.String(): true}
+	pages := 1
+	for next != "" && pages < s.maxPages && ctx.Err() == nil && !visited[next] {
+		visited[next] = true
+		u, err := url.Parse(next)
+		if err != nil || s.policies.skipReason(u.Hostname()) != "" {
+			break
+		}
+		r, pageBody := s.fetchExtract(ctx, next, "", internal.FormatHTML)
+		first.Attempts += r.Attempts
+		first.LimiterWaitMS += r.LimiterWaitMS
+		if r.Error != "" || !htmlTypes[r.ContentType] {
+			break
+		}
+		appendBlocks(merged, r.Content, seen)
+		pages++
+		if u, err = url.Parse(firstNonEmpty(r.FinalURL, next)); err != nil {
+			break
+		}
+		next = nextPageURL(pageBody, u, pages)
+	}
+	if pages == 1 {
+		return first
+	}
+
+	c := nodeCandidate(first.Extractor, merged, format)
+	first.Content = c.content
+	first.Format = format
+	first.Confidence = score(c, first.Title, first.Excerpt)
+	first.Pages = pages
+	return first
+}
+
+// renderAs returns r with its content, sanitized article HTML if r.Format
+// is FormatHTML, rendered in format.
+func renderAs(r internal.ArticleResult, format internal.Format) internal.ArticleResult {
+	if r.Format == format {
+		return r
+	}
+	if r.Format == internal.FormatHTML && r.Content != "" {
+		container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+		if nodes, err := html.ParseFragment(strings.NewReader(r.Content), container); err == nil {
+			for _, n := range nodes {
+				container.AppendChild(n)
+			}
+			r.Content = render(container, format)
+		}
+	}
+	r.Format = format
+	return r
+}
+
+// appendBlocks adds the top-level blocks of content, sanitized article
+// HTML, to dst, skipping pager links and blocks whose text is in seen.
+func appendBlocks(dst *html.Node, content string, seen map[string]bool) {
+	nodes, err := html.ParseFragment(strings.NewReader(content), dst)
+	if err != nil {
+		return
+	}
+	for _, n := range nodes {
+		key := strings.ToLower(tidyInline(textContent(n)))
+		if key != "" && (seen[key] || isPager(key)) {
+			continue
+		}
+		seen[key] = true
+		dst.AppendChild(n)
+	}
+}
+
+// isPager reports whether a lowercased block only navigates between pages:
+// "next", "page 2 of 3", or a short list of increasing page numbers such as
+// "« 1 2 3 … 10 »". Years, figures and other numeric paragraphs are kept.
+func isPager(key string) bool {
+	if pagerTextRe.MatchString(key) {
+		return true
+	}
+	if len(key) > maxPagerLen {
+		return false
+	}
+	fields := strings.FieldsFunc(key, func(r rune) bool {
+		return r == ' ' || r == '|' || r == '/' || r == '-' || r == '·'
+	})
+	numbers, last, nav := 0, 0, false
+	for _, f := range fields {
+		f = strings.Trim(f, "«‹»›←→<>….")
+		switch n, err := strconv.Atoi(f); {
+		case f == "" || f == "next" || f == "prev" || f == "previous":
+			nav = true
+		case err == nil && len(f) <= 3 && f[0] != '0' && n > last:
+			numbers, last = numbers+1, n
+		default:
+			return false
+		}
+	}
+	return numbers >= 3 || (numbers >= 1 && nav)
+}
+
+// nextPageURL finds the link from page number n at base to page n+1:
+// rel="next" within the same article, or a link that increments a page
+// parameter or a trailing page number in the path.
+func nextPageURL(body []byte, base *url.URL, n int) string {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	want := nextPageCandidates(base, n)
+	var byNumber string
+	for _, el := range dom.QuerySelectorAll(doc, "link[href], a[href]") {
+		u, err := base.Parse(strings.TrimSpace(dom.GetAttribute(el, "href")))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, base.Host) {
+			continue
+		}
+		u.Fragment = ""
+		rel := strings.Fields(strings.ToLower(dom.GetAttribute(el, "rel")))
+		// Some CMSs mark the next article as rel="next"; stay within this one.
+		if slices.Contains(rel, "next") && sameArticle(base, u, n) {
+			return u.String()
+		}
+		if byNumber == "" && want[pageKey(*u)] {
+			byNumber = u.String()
+		}
+	}
+	return byNumber
+}
+
+// pageKey identifies u regardless of query parameter order.
+func pageKey(u url.URL) string {
+	u.Fragment = ""
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+// nextPageCandidates lists the keys of the URLs page n+1 of base may have.
+func nextPageCandidates(base *url.URL, n int) map[string]bool {
+	next := strconv.Itoa(n + 1)
+	out := make(map[string]bool)
+
+	for _, p := range pageParams {
+		u := *base
+		q := u.Query()
+		if cur := q.Get(p); cur != "" && cur != strconv.Itoa(n) {
+			continue
+		}
+		q.Set(p, next)
+		u.RawQuery = q.Encode()
+		out[pageKey(u)] = true
+	}
+
+	stem := articlePath(base, n)
+	for _, path := range []string{stem + "/" + next, stem + "/page/" + next} {
+		for _, p := range []string{path, path + "/"} {
+			u := *base
+			u.Path, u.RawPath = p, ""
+			out[pageKey(u)] = true
+		}
+	}
+	return out
+}
+
+// articlePath is the path of u, page n of an article, without a trailing
+// slash or page number.
+func articlePath(u *url.URL, n int) string {
+	path := strings.TrimSuffix(u.Path, "/")
+	if m := pagePathRe.FindStringSubmatchIndex(u.Path); m != nil && n > 1 && u.Path[m[4]:m[5]] == strconv.Itoa(n) {
+		path = u.Path[:m[0]]
+	}
+	return path
+}
+
+// sameArticle reports whether u is another page of the article whose page
+// n is at base: the same path with other query parameters, or a sub-path.
+func sameArticle(base, u *url.URL, n int) bool {
+	stem := articlePath(base, n)
+	return articlePath(u, n+1) == stem || strings.HasPrefix(u.Path, stem+"/")
+}
@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		name, base, links string
+		n                 int
+		want              string
+	}{
+		{"rel next", "https://example.com/story", `<link rel="next" href="/story?page=2">`, 1, "https://example.com/story?page=2"},
+		{"rel next on an anchor", "https://example.com/story", `<a rel="next nofollow" href="/story/2#top">Next</a>`, 1, "https://example.com/story/2"},
+		{"rel next to another article", "https://example.com/news/story", `<a rel="next" href="/news/other-story">Next story</a>`, 1, ""},
+		{"page parameter", "https://example.com/story?id=7", `<a href="/story?page=2&id=7">2</a>`, 1, "https://example.com/story?page=2&id=7"},
+		{"page parameter from page 2", "https://example.com/story?p=2", `<a href="/story?p=1">1</a><a href="/story?p=3">3</a>`, 2, "https://example.com/story?p=3"},
+		{"path page", "https://example.com/story/", `<a href="/story/page/2/">2</a>`, 1, "https://example.com/story/page/2/"},
+		{"path number from page 2", "https://example.com/story/2", `<a href="/story/3">3</a>`, 2, "https://example.com/story/3"},
+		{"other host", "https://example.com/story", `<a href="https://other.example/story?page=2">2</a>`, 1, ""},
+		{"unrelated number", "https://example.com/story", `<a href="/story?page=3">3</a><a href="/other/2">x</a>`, 1, ""},
+	}
+	for _, tt := range tests {
+		got := nextPageURL([]byte(tt.links), mustParse(t, tt.base), tt.n)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSameArticle(t *testing.T) {
+	base := mustParse(t, "https://example.com/news/story/2")
+	for raw, want := range map[string]bool{
+		"https://example.com/news/story/3":        true,
+		"https://example.com/news/story?page=3":   true,
+		"https://example.com/news/story/page/3":   true,
+		"https://example.com/news/other-story":    false,
+		"https://example.com/news":                false,
+		"https://example.com/news/story-part-two": false,
+	} {
+		if got := sameArticle(base, mustParse(t, raw), 2); got != want {
+			t.Errorf("sameArticle(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestAppendBlocks(t *testing.T) {
+	merged := parseTestHTML(t, "").LastChild.LastChild // <body>
+	seen := make(map[string]bool)
+	appendBlocks(merged, `<p>First part.</p><p>By A. Writer</p><p>Page 1 of 2</p>`, seen)
+	appendBlocks(merged, `<p>By A. Writer</p><p>Second part.</p><p>2024</p><p>1 000 000</p><p>« 1 2 »</p><p>Next page</p>`, seen)
+	if got := render(merged, internal.FormatText); got != "First part.\n\nBy A. Writer\n\nSecond part.\n\n2024\n\n1 000 000" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestIsPager(t *testing.T) {
+	for key, want := range map[string]bool{
+		"next":                true,
+		"page 2 of 3":         true,
+		"»":                   true,
+		"1 2 3":               true,
+		"« 1 2 »":             true,
+		"‹ prev 1 | 2 | 3 …":  true,
+		"1 2 3 … 10 next ›":   true,
+		"← 2":                 true,
+		"2024":                false,
+		"1 000 000":           false,
+		"12 - 15":             false,
+		"3 2 1":               false,
+		"2019 2020 2021":      false,
+		"1.5":                 false,
+		"page 1 of 2 of text": false,
+	} {
+		if got := isPager(key); got != want {
+			t.Errorf("isPager(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+// countingExtractor is a ContextExtractor counting the pages it extracts.
+type countingExtractor struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (e *countingExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	return e.ExtractContext(context.Background(), doc)
+}
+
+func (e *countingExtractor) ExtractContext(ctx context.Context, doc Document) (internal.ArticleResult, error) {
+	e.mu.Lock()
+	e.calls[doc.URL]++
+	e.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return internal.ArticleResult{}, err
+	}
+	return textExtractor{}.Extract(doc)
+}
+
+func TestScrapeStitchesPages(t *testing.T) {
+	page := func(text, next string) http.HandlerFunc {
+		links := ""
+		if next != "" {
+			links = `<a rel="next" href="` + next + `">Next page</a>`
+		}
+		return serveBody("text/html", "<p>"+text+"</p><p>Share this story</p>"+links)
+	}
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/story":   page("Part one.", "/story/2"),
+		"/story/2": page("Part two.", "/story/3"),
+		"/story/3": page("Part three.", ""),
+	})
+
+	tests := []struct {
+		maxPages int
+		format   internal.Format
+		want     string
+		pages    int
+	}{
+		{1, internal.FormatText, "Part one.\n\nShare this story\n\nNext page", 1},
+		{2, internal.FormatText, "Part one.\n\nShare this story\n\nPart two.", 2},
+		{5, internal.FormatTextFlat, "Part one. Share this story Part two. Part three.", 3},
+		{5, internal.FormatMarkdown, "Part one.\n\nShare this story\n\nPart two.\n\nPart three.", 3},
+	}
+	for _, tt := range tests {
+		before := len(srv.recorded())
+		extractor := &countingExtractor{calls: make(map[string]int)}
+		s := New(stubFetcher(), extractor, NewPool(1, 10), WithPagination(tt.maxPages))
+		rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{srv.URL + "/story"}, Format: tt.format})
+		if err != nil {
+			t.Fatalf("Scrape: %v", err)
+		}
+		r := rs[0]
+		if r.Content != tt.want || r.Pages != tt.pages || r.Format != tt.format {
+			t.Errorf("max %d pages, %s: got %q in %d pages as %s, want %q in %d", tt.maxPages, tt.format, r.Content, r.Pages, r.Format, tt.want, tt.pages)
+		}
+		if fetched := len(srv.recorded()) - before; fetched != tt.pages || r.Attempts != tt.pages {
+			t.Errorf("max %d pages: fetched %d pages, %d attempts, want %d", tt.maxPages, fetched, r.Attempts, tt.pages)
+		}
+		for u, n := range extractor.calls {
+			if n != 1 {
+				t.Errorf("max %d pages: %s extracted %d times", tt.maxPages, strings.TrimPrefix(u, srv.URL), n)
+			}
+		}
+	}
+}
@@ -0,0 +1,1052 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Limits on PDF parsing, which runs on untrusted input.
+const (
+	// maxPDFStream caps one decompressed stream.
+	maxPDFStream = 16 << 20
+	// maxPDFInflate caps all decompressed data of one document.
+	maxPDFInflate = 64 << 20
+	// maxPDFDepth bounds nesting of page trees, form XObjects and values.
+	maxPDFDepth = 32
+	// maxPDFLex caps the bytes lexed for one document, counting bytes that
+	// damaged objects make the parser read more than once.
+	maxPDFLex = 256 << 20
+	// maxPDFCMap caps the ToUnicode entries read for one document; a short
+	// bfrange can expand to 65536 of them.
+	maxPDFCMap = 1 << 20
+)
+
+var (
+	// errPDFEncrypted reports a document whose streams cannot be read without decryption.
+	errPDFEncrypted = errors.New("PDF is encrypted")
+	// errPDFTooComplex reports a document that needs more work than the limits allow.
+	errPDFTooComplex = errors.New("PDF too complex to read")
+)
+
+// PDF values are nil, bool, float64, pdfName, pdfString, []any, pdfDict,
+// pdfRef, *pdfStream and, in content streams, pdfOp.
+type (
+	pdfName   string
+	pdfString string // raw bytes
+	pdfOp     string // content stream operator
+	pdfDict   map[pdfName]any
+	pdfRef    struct{ num, gen int }
+	pdfStream struct {
+		dict pdfDict
+		raw  []byte // as stored, still filtered
+	}
+)
+
+// pdfDoc is a parsed PDF file.
+type pdfDoc struct {
+	ctx      context.Context
+	objects  map[int]any
+	trailer  pdfDict
+	fonts    map[pdfRef]*pdfFont // fonts read so far; pages usually share them
+	inflated int                 // bytes decompressed so far, against maxPDFInflate
+	lexed    int                 // bytes lexed so far, against maxPDFLex
+	mapped   int                 // ToUnicode entries read so far, against maxPDFCMap
+}
+
+// err reports why work on d has to stop: its context is done or a limit is
+// used up.
+func (d *pdfDoc) err() error {
+	if d.lexed > maxPDFLex || d.mapped > maxPDFCMap {
+		return errPDFTooComplex
+	}
+	return d.ctx.Err()
+}
+
+// pdfObjRe finds indirect object headers, pdfTrailerRe trailer dictionaries.
+var (
+	pdfObjRe     = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailerRe = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// parsePDF reads the objects of data. Rather than trusting the xref table,
+// which is often damaged, it scans for object headers; later definitions
+// win, as in incremental updates. Objects inside object streams are read too.
+// Work stops with an error when ctx is done or the limits above are reached.
+func parsePDF(ctx context.Context, data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\r "), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF document")
+	}
+	d := &pdfDoc{ctx: ctx, objects: make(map[int]any), trailer: pdfDict{}, fonts: make(map[pdfRef]*pdfFont)}
+	var objStreams []*pdfStream
+	end := 0 // end of the last object read
+	for _, m := range pdfObjRe.FindAllSubmatchIndex(data, -1) {
+		// A header must start a token, outside the objects already read:
+		// strings and streams may contain text that looks like one.
+		if m[0] < end || (m[0] > 0 && !isPDFSpace(data[m[0]-1]) && !isPDFDelim(data[m[0]-1])) {
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		lx := &pdfLexer{data: data, pos: m[1], doc: d}
+		v, err := lx.object(0)
+		if err != nil {
+			if err := d.err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if dict, ok := v.(pdfDict); ok {
+			if s := lx.stream(dict); s != nil {
+				v = s
+				switch dict["Type"] {
+				case pdfName("ObjStm"):
+					objStreams = append(objStreams, s)
+				case pdfName("XRef"):
+					mergeTrailer(d.trailer, dict)
+				}
+			}
+		}
+		d.objects[num] = v
+		end = lx.pos
+	}
+	for _, m := range pdfTrailerRe.FindAllIndex(data, -1) {
+		lx := &pdfLexer{data: data, pos: m[1] - 2, doc: d}
+		if v, err := lx.object(0); err == nil {
+			if dict, ok := v.(pdfDict); ok {
+				mergeTrailer(d.trailer, dict)
+			}
+		}
+	}
+	for _, s := range objStreams {
+		d.readObjStream(s)
+	}
+	if err := d.err(); err != nil {
+		return nil, err
+	}
+	if _, ok := d.trailer["Encrypt"]; ok {
+		return d, errPDFEncrypted
+	}
+	return d, nil
+}
+
+// mergeTrailer copies the document-level keys of a trailer or xref stream.
+// Later trailers belong to later updates, so they overwrite.
+func mergeTrailer(dst, src pdfDict) {
+	for _, k := range []pdfName{"Root", "Info", "Encrypt"} {
+		if v, ok := src[k]; ok {
+			dst[k] = v
+		}
+	}
+}
+
+// readObjStream adds the objects stored in an object stream, unless they
+// are also defined directly.
+func (d *pdfDoc) readObjStream(s *pdfStream) {
+	data, err := d.decode(s)
+	if err != nil {
+		return
+	}
+	n, _ := d.resolve(s.dict["N"]).(float64)
+	f, _ := d.resolve(s.dict["First"]).(float64)
+	first, ok := pdfOffset(f, len(data))
+	if !ok {
+		return
+	}
+	header := &pdfLexer{data: data[:first], doc: d}
+	for range int(min(n, 100_000)) {
+		num, err1 := header.object(0)
+		off, err2 := header.object(0)
+		nf, ok1 := num.(float64)
+		of, ok2 := off.(float64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return
+		}
+		if _, exists := d.objects[int(nf)]; exists {
+			continue
+		}
+		rel, ok := pdfOffset(of, len(data)-first-1)
+		if !ok {
+			continue
+		}
+		lx := &pdfLexer{data: data, pos: first + rel, doc: d}
+		if v, err := lx.object(0); err == nil {
+			d.objects[int(nf)] = v
+		}
+	}
+}
+
+// pdfOffset converts a number read from the file to an offset or length in
+// [0, limit]. Checking the float first keeps huge or non-finite values from
+// wrapping around when converted.
+func pdfOffset(f float64, limit int) (int, bool) {
+	if math.IsNaN(f) || f < 0 || f > float64(limit) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// resolve follows references until v is a direct value.
+func (d *pdfDoc) resolve(v any) any {
+	for range maxPDFDepth {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict {
+	switch t := d.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case *pdfStream:
+		return t.dict
+	}
+	return nil
+}
+
+// decode returns the stream's data with its filters undone. Only
+// FlateDecode is supported, which covers the text content of nearly all
+// PDFs; images use other filters and are not needed.
+func (d *pdfDoc) decode(s *pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+	data := s.raw
+	for _, f := range filters {
+		switch d.resolve(f) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("inflate: %w", err)
+			}
+			limit := min(maxPDFStream, maxPDFInflate-d.inflated)
+			// Damaged streams often fail at the very end; keep what was read.
+			out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+			if len(out) > limit {
+				return nil, errors.New("PDF stream too large")
+			}
+			if err != nil && len(out) == 0 {
+				return nil, fmt.Errorf("inflate: %w", err)
+			}
+			d.inflated += len(out)
+			data = out
+		default:
+			return nil, fmt.Errorf("unsupported PDF filter %v", f)
+		}
+	}
+	return data, nil
+}
+
+// pages returns the page dictionaries in order, each with its inherited
+// Resources filled in. Without a usable page tree, every page object is
+// returned in object number order.
+func (d *pdfDoc) pages() []pdfDict {
+	var out []pdfDict
+	// A node listed more than once would be walked again with all its kids,
+	// which multiplies at every level.
+	seen := make(map[pdfRef]bool)
+	var walk func(v, resources any, depth int)
+	walk = func(v, resources any, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		node := d.dict(v)
+		if node == nil || depth > maxPDFDepth {
+			return
+		}
+		if r, ok := node["Resources"]; ok {
+			resources = r
+		}
+		if kids, ok := d.resolve(node["Kids"]).([]any); ok {
+			for _, k := range kids {
+				walk(k, resources, depth+1)
+			}
+			return
+		}
+		page := pdfDict{"Contents": node["Contents"], "Resources": resources}
+		out = append(out, page)
+	}
+	root := d.dict(d.trailer["Root"])
+	walk(root["Pages"], nil, 0)
+	if len(out) > 0 {
+		return out
+	}
+
+	nums := make([]int, 0, len(d.objects))
+	for n := range d.objects {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	for _, n := range nums {
+		if dict, ok := d.objects[n].(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			out = append(out, dict)
+		}
+	}
+	return out
+}
+
+// info returns the document information dictionary.
+func (d *pdfDoc) info() pdfDict {
+	return d.dict(d.trailer["Info"])
+}
+
+// text returns the decoded text string stored under key in dict.
+func (d *pdfDoc) text(dict pdfDict, key pdfName) string {
+	s, _ := d.resolve(dict[key]).(pdfString)
+	return strings.TrimSpace(decodeTextString(s))
+}
+
+// decodeTextString decodes a PDF text string: UTF-16BE with a byte-order
+// mark, UTF-8 with one, or PDFDocEncoding.
+func decodeTextString(s pdfString) string {
+	b := []byte(s)
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeUTF16BE(b[2:])
+	case bytes.HasPrefix(b, utf8BOM):
+		return string(b[3:])
+	}
+	return decodeWinAnsi(b)
+}
+
+func decodeUTF16BE(b []byte) string {
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+// winAnsiHigh maps the bytes 0x80-0x9F of WinAnsiEncoding, which is what
+// simple fonts without a ToUnicode map nearly always use; the rest of the
+// range matches Latin-1.
+var winAnsiHigh = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+func decodeWinAnsi(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case c >= 0x80 && c < 0xA0:
+			if r := winAnsiHigh[c-0x80]; r != 0 {
+				sb.WriteRune(r)
+			}
+		case c < 0x20 && c != '\t' && c != '\n' && c != '\r':
+			// Control codes carry no text.
+		default:
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+// pdfDateRe matches the D:YYYYMMDDHHmmSSOHH'mm' date format.
+var pdfDateRe = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(Z|[+-]\d{2}'?\d{2}'?)?`)
+
+// pdfDate converts a PDF date to RFC 3339, or "" if it does not parse.
+func pdfDate(s string) string {
+	m := pdfDateRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return ""
+	}
+	part := func(i int, def string) string {
+		if m[i] == "" {
+			return def
+		}
+		return m[i]
+	}
+	zone := "Z"
+	if z := m[7]; z != "" && z != "Z" {
+		z = strings.ReplaceAll(z, "'", "")
+		zone = z[:3] + ":" + z[3:]
+	}
+	return firstDate(fmt.Sprintf("%s-%s-%sT%s:%s:%s%s",
+		m[1], part(2, "01"), part(3, "01"), part(4, "00"), part(5, "00"), part(6, "00"), zone))
+}
+
+// pdfLexer reads PDF tokens and objects from data.
+type pdfLexer struct {
+	data []byte
+	pos  int
+	doc  *pdfDoc // charged for the bytes read, if set
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+var errPDFSyntax = errors.New("PDF syntax error")
+
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		switch {
+		case isPDFSpace(c):
+			lx.pos++
+		case c == '%':
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// object reads the next value. Bare words other than true, false and null
+// come back as pdfOp; "R" after two integers forms a reference.
+func (lx *pdfLexer) object(depth int) (any, error) {
+	if depth > maxPDFDepth {
+		return nil, errPDFSyntax
+	}
+	if depth == 0 && lx.doc != nil {
+		if err := lx.doc.err(); err != nil {
+			return nil, err
+		}
+		defer func(start int) { lx.doc.lexed += lx.pos - start }(lx.pos)
+	}
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil, io.EOF
+	}
+	switch c := lx.data[lx.pos]; {
+	case c == '/':
+		return lx.name(), nil
+	case c == '(':
+		return lx.literal(), nil
+	case c == '<' && lx.peek(1) == '<':
+		lx.pos += 2
+		dict := pdfDict{}
+		for {
+			lx.skipSpace()
+			if lx.pos >= len(lx.data) {
+				return nil, errPDFSyntax
+			}
+			if lx.data[lx.pos] == '>' && lx.peek(1) == '>' {
+				lx.pos += 2
+				return dict, nil
+			}
+			k, err := lx.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(pdfName)
+			if !ok {
+				return nil, errPDFSyntax
+			}
+			v, err := lx.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = v
+		}
+	case c == '<':
+		return lx.hex(), nil
+	case c == '[':
+		lx.pos++
+		var arr []any
+		for {
+			lx.skipSpace()
+			if lx.pos >= len(lx.data) {
+				return nil, errPDFSyntax
+			}
+			if lx.data[lx.pos] == ']' {
+				lx.pos++
+				return arr, nil
+			}
+			v, err := lx.object(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	case c == ')' || c == '>' || c == ']' || c == '{' || c == '}':
+		lx.pos++
+		return pdfOp(c), nil
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return lx.number(), nil
+	}
+
+	word := lx.word()
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfOp(word), nil
+}
+
+func (lx *pdfLexer) peek(off int) byte {
+	if lx.pos+off < len(lx.data) {
+		return lx.data[lx.pos+off]
+	}
+	return 0
+}
+
+func (lx *pdfLexer) word() string {
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos == start {
+		lx.pos++ // never stall on a stray delimiter
+	}
+	return string(lx.data[start:lx.pos])
+}
+
+// number reads a number, or a reference if it is followed by a generation
+// number and R.
+func (lx *pdfLexer) number() any {
+	w := lx.word()
+	f, err := strconv.ParseFloat(w, 64)
+	if err != nil {
+		return pdfOp(w)
+	}
+	if f < 0 || f != math.Trunc(f) || strings.Contains(w, ".") {
+		return f
+	}
+	save := lx.pos
+	lx.skipSpace()
+	gen := lx.pos
+	for lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '9' {
+		lx.pos++
+	}
+	if lx.pos > gen {
+		g, _ := strconv.Atoi(string(lx.data[gen:lx.pos]))
+		lx.skipSpace()
+		if lx.peek(0) == 'R' && (lx.pos+1 >= len(lx.data) || isPDFSpace(lx.peek(1)) || isPDFDelim(lx.peek(1))) {
+			lx.pos++
+			return pdfRef{num: int(f), gen: g}
+		}
+	}
+	lx.pos = save
+	return f
+}
+
+func (lx *pdfLexer) name() pdfName {
+	lx.pos++ // '/'
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFSpace(lx.data[lx.pos]) && !isPDFDelim(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	raw := string(lx.data[start:lx.pos])
+	if !strings.Contains(raw, "#") {
+		return pdfName(raw)
+	}
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(raw[i])
+	}
+	return pdfName(b.String())
+}
+
+func (lx *pdfLexer) literal() pdfString {
+	lx.pos++ // '('
+	var b []byte
+	nesting := 0
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				return pdfString(b)
+			}
+			nesting--
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return pdfString(b)
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if lx.peek(0) == '\n' {
+					lx.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for range 2 {
+						if d := lx.peek(0); d >= '0' && d <= '7' {
+							v = v*8 + int(d-'0')
+							lx.pos++
+						}
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return pdfString(b)
+}
+
+func (lx *pdfLexer) hex() pdfString {
+	lx.pos++ // '<'
+	var digits []byte
+	for lx.pos < len(lx.data) && lx.data[lx.pos] != '>' {
+		if c := lx.data[lx.pos]; !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+		lx.pos++
+	}
+	lx.pos++ // '>'
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, 0, len(digits)/2)
+	for i := 0; i+1 < len(digits); i += 2 {
+		v, err := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if err != nil {
+			break
+		}
+		out = append(out, byte(v))
+	}
+	return pdfString(out)
+}
+
+// stream reads the stream body following dict, if there is one.
+func (lx *pdfLexer) stream(dict pdfDict) *pdfStream {
+	save := lx.pos
+	lx.skipSpace()
+	if !bytes.HasPrefix(lx.data[lx.pos:], []byte("stream")) {
+		lx.pos = save
+		return nil
+	}
+	start := lx.pos + len("stream")
+	if bytes.HasPrefix(lx.data[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(lx.data) && (lx.data[start] == '\n' || lx.data[start] == '\r') {
+		start++
+	}
+	// Trust a direct Length only if endstream follows it.
+	n, isNum := dict["Length"].(float64)
+	if length, ok := pdfOffset(n, len(lx.data)-start); isNum && ok {
+		end := start + length
+		if bytes.HasPrefix(bytes.TrimLeft(lx.data[end:min(end+32, len(lx.data))], "\r\n \t"), []byte("endstream")) {
+			lx.pos = end
+			return &pdfStream{dict: dict, raw: lx.data[start:end]}
+		}
+	}
+	i := bytes.Index(lx.data[start:], []byte("endstream"))
+	if lx.doc != nil {
+		if i < 0 {
+			lx.doc.lexed += len(lx.data) - start
+		} else {
+			lx.doc.lexed += i
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+	end := start + i
+	raw := bytes.TrimSuffix(bytes.TrimSuffix(lx.data[start:end], []byte("\n")), []byte("\r"))
+	lx.pos = end
+	return &pdfStream{dict: dict, raw: raw}
+}
+
+// pdfFont maps the character codes of a font to text.
+type pdfFont struct {
+	twoByte bool              // Type0 fonts use two-byte codes
+	cmap    map[uint32]string // from the ToUnicode stream
+}
+
+func (d *pdfDoc) font(v any) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if f, ok := d.fonts[ref]; isRef && ok {
+		return f
+	}
+	dict := d.dict(v)
+	f := &pdfFont{twoByte: dict["Subtype"] == pdfName("Type0")}
+	if s, ok := d.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := d.decode(s); err == nil {
+			f.cmap = d.toUnicode(data)
+		}
+	}
+	if isRef {
+		d.fonts[ref] = f
+	}
+	return f
+}
+
+func (f *pdfFont) decode(s pdfString) string {
+	b := []byte(s)
+	if f == nil {
+		return decodeWinAnsi(b)
+	}
+	if f.twoByte {
+		var sb strings.Builder
+		for i := 0; i+1 < len(b); i += 2 {
+			code := uint32(b[i])<<8 | uint32(b[i+1])
+			if t, ok := f.cmap[code]; ok {
+				sb.WriteString(t)
+			} else if f.cmap == nil {
+				// Without a map, Identity-H codes are often Unicode.
+				sb.WriteRune(rune(code))
+			}
+		}
+		return sb.String()
+	}
+	if f.cmap == nil {
+		return decodeWinAnsi(b)
+	}
+	var sb strings.Builder
+	for _, c := range b {
+		if t, ok := f.cmap[uint32(c)]; ok {
+			sb.WriteString(t)
+		} else {
+			sb.WriteString(decodeWinAnsi([]byte{c}))
+		}
+	}
+	return sb.String()
+}
+
+// toUnicode reads the bfchar and bfrange sections of a ToUnicode CMap.
+func (d *pdfDoc) toUnicode(data []byte) map[uint32]string {
+	cmap := make(map[uint32]string)
+	lx := &pdfLexer{data: data, doc: d}
+	code := func(v any) (uint32, bool) {
+		s, ok := v.(pdfString)
+		if !ok || len(s) == 0 || len(s) > 4 {
+			return 0, false
+		}
+		var n uint32
+		for i := 0; i < len(s); i++ {
+			n = n<<8 | uint32(s[i])
+		}
+		return n, true
+	}
+	utf := func(v any) string {
+		s, _ := v.(pdfString)
+		return decodeUTF16BE([]byte(s))
+	}
+	for {
+		v, err := lx.object(0)
+		if err != nil {
+			return cmap
+		}
+		switch v {
+		case pdfOp("beginbfchar"):
+			for {
+				src, err := lx.object(0)
+				if err != nil || src == pdfOp("endbfchar") {
+					break
+				}
+				dst, _ := lx.object(0)
+				if c, ok := code(src); ok {
+					cmap[c] = utf(dst)
+					d.mapped++
+				}
+			}
+		case pdfOp("beginbfrange"):
+			for {
+				lo, err := lx.object(0)
+				if err != nil || lo == pdfOp("endbfrange") {
+					break
+				}
+				hi, _ := lx.object(0)
+				dst, _ := lx.object(0)
+				l, ok1 := code(lo)
+				h, ok2 := code(hi)
+				if !ok1 || !ok2 || h < l || h-l > 0xFFFF {
+					continue
+				}
+				switch t := dst.(type) {
+				case pdfString:
+					base := []rune(utf(t))
+					if len(base) == 0 {
+						continue
+					}
+					for c := l; c <= h && d.mapped <= maxPDFCMap; c++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(c - l)
+						cmap[c] = string(r)
+						d.mapped += len(r)
+					}
+				case []any:
+					for i, item := range t {
+						if l+uint32(i) > h {
+							break
+						}
+						cmap[l+uint32(i)] = utf(item)
+						d.mapped++
+					}
+				}
+			}
+		}
+	}
+}
+
+// pdfLine is a run of text on one baseline.
+type pdfLine struct {
+	text string
+	y    float64
+}
+
+// pageLines extracts the text lines of one page.
+func (d *pdfDoc) pageLines(page pdfDict) []pdfLine {
+	if d.err() != nil {
+		return nil
+	}
+	var content []byte
+	switch c := d.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		content, _ = d.decode(c)
+	case []any:
+		for _, item := range c {
+			if s, ok := d.resolve(item).(*pdfStream); ok {
+				if data, err := d.decode(s); err == nil {
+					content = append(append(content, data...), '\n')
+				}
+			}
+		}
+	}
+	var lines []pdfLine
+	d.runContent(content, d.dict(page["Resources"]), &lines, make(map[pdfName]*pdfFont), 0)
+	return lines
+}
+
+// runContent interprets the text operators of a content stream, appending
+// to lines. Form XObjects are followed.
+func (d *pdfDoc) runContent(content []byte, resources pdfDict, lines *[]pdfLine, fonts map[pdfName]*pdfFont, depth int) {
+	if depth > maxPDFDepth/4 {
+		return
+	}
+	fontDicts := d.dict(resources["Font"])
+	var (
+		lx       = &pdfLexer{data: content, doc: d}
+		operands []any
+		font     *pdfFont
+		cur      strings.Builder
+		y, scale = 0.0, 1.0
+		leading  = 0.0
+	)
+	flush := func() {
+		if t := strings.TrimSpace(cur.String()); t != "" {
+			*lines = append(*lines, pdfLine{text: t, y: y})
+		}
+		cur.Reset()
+	}
+	newline := func(dy float64) {
+		if dy != 0 {
+			flush()
+			y += dy * scale
+		} else if cur.Len() > 0 {
+			cur.WriteByte(' ')
+		}
+	}
+	show := func(v any) {
+		switch t := v.(type) {
+		case pdfString:
+			cur.WriteString(font.decode(t))
+		case []any:
+			for _, item := range t {
+				switch e := item.(type) {
+				case pdfString:
+					cur.WriteString(font.decode(e))
+				case float64:
+					// Large negative kerning separates words in justified text.
+					if e < -200 {
+						cur.WriteByte(' ')
+					}
+				}
+			}
+		}
+	}
+	num := func(i int) float64 {
+		if i < len(operands) {
+			f, _ := operands[i].(float64)
+			return f
+		}
+		return 0
+	}
+
+	for {
+		v, err := lx.object(0)
+		if err != nil {
+			break
+		}
+		op, isOp := v.(pdfOp)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+		switch op {
+		case "BI":
+			// Skip inline image data up to EI; without one, the rest is image data.
+			if i := bytes.Index(content[lx.pos:], []byte("EI")); i >= 0 {
+				lx.pos += i + 2
+			} else {
+				lx.pos = len(content)
+			}
+		case "BT":
+			y, scale = 0, 1
+		case "ET":
+			flush()
+		case "Tf":
+			if len(operands) > 0 {
+				if name, ok := operands[0].(pdfName); ok {
+					if fonts[name] == nil {
+						fonts[name] = d.font(fontDicts[name])
+					}
+					font = fonts[name]
+				}
+			}
+		case "TL":
+			leading = num(0)
+		case "Td":
+			newline(num(1))
+		case "TD":
+			leading = -num(1)
+			newline(num(1))
+		case "T*":
+			newline(-leading)
+		case "Tm":
+			if len(operands) >= 6 {
+				if ny := num(5); ny != y {
+					flush()
+					y = ny
+				} else if cur.Len() > 0 {
+					cur.WriteByte(' ')
+				}
+				if s := num(3); s != 0 {
+					scale = s
+				}
+			}
+		case "Tj", "TJ":
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline(-leading)
+			if len(operands) > 0 {
+				show(operands[len(operands)-1])
+			}
+		case "Do":
+			if len(operands) > 0 {
+				if name, ok := operands[0].(pdfName); ok {
+					xobjects := d.dict(resources["XObject"])
+					if s, ok := d.resolve(xobjects[name]).(*pdfStream); ok && s.dict["Subtype"] == pdfName("Form") {
+						if data, err := d.decode(s); err == nil {
+							flush()
+							res := d.dict(s.dict["Resources"])
+							if res == nil {
+								res = resources
+							}
+							d.runContent(data, res, lines, make(map[pdfName]*pdfFont), depth+1)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	flush()
+}
+
+// pdfParagraphs joins the lines of all pages into paragraphs. A paragraph
+// ends at a page break or where the gap to the next line is clearly larger
+// than the usual line spacing. Words hyphenated across lines are rejoined.
+func pdfParagraphs(pages [][]pdfLine) []string {
+	var gaps []float64
+	for _, lines := range pages {
+		for i := 1; i < len(lines); i++ {
+			if g := math.Abs(lines[i-1].y - lines[i].y); g > 0 {
+				gaps = append(gaps, g)
+			}
+		}
+	}
+	spacing := 0.0
+	if len(gaps) > 0 {
+		sort.Float64s(gaps)
+		spacing = gaps[len(gaps)/2]
+	}
+
+	var paras []string
+	var cur strings.Builder
+	end := func() {
+		if t := strings.TrimSpace(cur.String()); t != "" {
+			paras = append(paras, t)
+		}
+		cur.Reset()
+	}
+	for _, lines := range pages {
+		for i, l := range lines {
+			if i > 0 && spacing > 0 && math.Abs(lines[i-1].y-l.y) > spacing*1.4 {
+				end()
+			}
+			text := tidyInline(l.text)
+			if cur.Len() > 0 {
+				prev := cur.String()
+				if strings.HasSuffix(prev, "-") && startsLower(text) {
+					cur.Reset()
+					cur.WriteString(strings.TrimSuffix(prev, "-"))
+				} else {
+					cur.WriteByte(' ')
+				}
+			}
+			cur.WriteString(text)
+		}
+		end()
+	}
+	return paras
+}
+
+func startsLower(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r != utf8.RuneError && strings.ToLower(string(r)) == string(r) && strings.ToUpper(string(r)) != string(r)
+}
@@ -0,0 +1,266 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+// buildPDF assembles a PDF from the bodies of objects 1, 2, ... and a
+// trailer pointing at object 1 as the catalog and at info, if not 0.
+func buildPDF(info int, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R")
+	if info > 0 {
+		fmt.Fprintf(&b, " /Info %d 0 R", info)
+	}
+	b.WriteString(" >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+// pdfStreamObj returns a stream object holding data, Flate-compressed if
+// deflate is set.
+func pdfStreamObj(dict, data string, deflate bool) string {
+	if deflate {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write([]byte(data))
+		w.Close()
+		data = z.String()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// samplePDF is a one-page document with a simple and a composite font.
+func samplePDF() []byte {
+	content := "BT /F1 12 Tf 72 720 Td (Hello PDF world,) Tj 0 -14 Td (second line,) Tj 0 -14 Td (third line.) Tj ET\n" +
+		"BT /F2 12 Tf 72 600 Td <000100020003> Tj ET"
+	cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n" +
+		"1 beginbfchar <0001> <0048> endbfchar\n" +
+		"1 beginbfrange <0002> <0003> <0069> endbfrange\n" +
+		"endcmap CMapName currentdict /CMap defineresource pop end end"
+	return buildPDF(7,
+		"<< /Type /Catalog /Pages 2 0 R /Lang (en-GB) >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 8 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Sans /ToUnicode 6 0 R >>",
+		pdfStreamObj("", cmap, true),
+		"<< /Title (Sample \\(PDF\\)) /Author <FEFF004100640061> /CreationDate (D:20240102030405+01'00') >>",
+		pdfStreamObj("", content, true),
+	)
+}
+
+func TestPDFExtractor(t *testing.T) {
+	r, err := PDFExtractor{}.Extract(Document{URL: "https://example.com/a.pdf", HTML: samplePDF(), Format: internal.FormatText})
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	want := internal.ArticleResult{
+		Title:       "Sample (PDF)",
+		Byline:      "Ada",
+		PublishedAt: "2024-01-02T03:04:05+01:00",
+		Language:    "en-GB",
+		Content:     "Hello PDF world, second line, third line.\n\nHij",
+	}
+	if r.Title != want.Title || r.Byline != want.Byline || r.PublishedAt != want.PublishedAt ||
+		r.Language != want.Language || r.Content != want.Content {
+		t.Errorf("got title %q byline %q published %q language %q content %q, want %q %q %q %q %q",
+			r.Title, r.Byline, r.PublishedAt, r.Language, r.Content,
+			want.Title, want.Byline, want.PublishedAt, want.Language, want.Content)
+	}
+}
+
+func TestPDFExtractorEncrypted(t *testing.T) {
+	data := bytes.Replace(buildPDF(0, "<< /Type /Catalog >>"), []byte("/Root 1 0 R"), []byte("/Root 1 0 R /Encrypt << >>"), 1)
+	_, err := PDFExtractor{}.Extract(Document{URL: "https://example.com/a.pdf", HTML: data})
+	if e := classify(err); !errors.Is(err, errPDFEncrypted) || e.Code != internal.ErrExtractionEmpty {
+		t.Errorf("got %v, want an extraction_empty error for an encrypted PDF", err)
+	}
+}
+
+func TestPDFExtractorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := PDFExtractor{}.ExtractContext(ctx, Document{URL: "https://example.com/a.pdf", HTML: samplePDF()})
+	if !errors.Is(err, context.Canceled) || classify(err).Code == internal.ErrExtractionEmpty {
+		t.Errorf("got %v, want a cancellation", err)
+	}
+}
+
+func TestPDFLexerLiteral(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`(plain)`, "plain"},
+		{`(a \(b\) c)`, "a (b) c"},
+		{`(nested (parens) kept)`, "nested (parens) kept"},
+		{`(\101\102\7x)`, "AB\ax"},
+		{`(tab\tnewline\n)`, "tab\tnewline\n"},
+		{"(split \\\nline)", "split line"},
+		{`(unclosed`, "unclosed"},
+	}
+	for _, tt := range tests {
+		lx := &pdfLexer{data: []byte(tt.in)}
+		v, err := lx.object(0)
+		if s, ok := v.(pdfString); err != nil || !ok || string(s) != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.in, v, err, tt.want)
+		}
+	}
+}
+
+func TestPDFLexerObjects(t *testing.T) {
+	lx := &pdfLexer{data: []byte(`<< /Kids [1 0 R 2 0 R] /N -1.5 /Name /A#20B /Hex <48656C6C6F> /On true >>`)}
+	v, err := lx.object(0)
+	if err != nil {
+		t.Fatalf("object: %v", err)
+	}
+	dict := v.(pdfDict)
+	kids, _ := dict["Kids"].([]any)
+	if len(kids) != 2 || kids[1] != (pdfRef{num: 2}) || dict["N"] != -1.5 || dict["Name"] != pdfName("A B") ||
+		dict["Hex"] != pdfString("Hello") || dict["On"] != true {
+		t.Errorf("got %#v", dict)
+	}
+}
+
+func TestParsePDFSkipsHeadersInsideObjects(t *testing.T) {
+	data := buildPDF(0,
+		"<< /Type /Catalog >>",
+		"(a string quoting 1 0 obj (fake) endobj)",
+		pdfStreamObj("", "1 0 obj (also fake) endobj", false),
+	)
+	d, err := parsePDF(context.Background(), data)
+	if err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+	if _, ok := d.objects[1].(pdfDict); !ok {
+		t.Errorf("object 1 is %#v, want the catalog", d.objects[1])
+	}
+	if _, ok := d.objects[3].(*pdfStream); !ok {
+		t.Errorf("object 3 is %#v, want a stream", d.objects[3])
+	}
+}
+
+func TestParsePDFObjectStream(t *testing.T) {
+	objects := "2 0 5 33 << /Type /Pages /Kids [5 0 R] >> << /Type /Page /Contents 4 0 R >>"
+	data := buildPDF(0,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] >>", // shadows the copy in the object stream
+		pdfStreamObj("/Type /ObjStm /N 2 /First 9", objects, true),
+		pdfStreamObj("", "BT (Stored text) Tj ET", false),
+	)
+	d, err := parsePDF(context.Background(), data)
+	if err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+	if kids, _ := d.dict(d.objects[2])["Kids"].([]any); len(kids) != 0 {
+		t.Errorf("object stream replaced object 2: %v", kids)
+	}
+	if typ := d.dict(d.objects[5])["Type"]; typ != pdfName("Page") {
+		t.Errorf("object 5 has type %v, want Page", typ)
+	}
+}
+
+// TestParsePDFLimits feeds inputs that made parsing quadratic or
+// exponential. Each must stop early, with or without an error.
+func TestParsePDFLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{
+			"unclosed strings in dictionaries",
+			append([]byte("%PDF-1.7\n"), strings.Repeat("1 0 obj << /A (", 20_000)...),
+			errPDFTooComplex,
+		},
+		{
+			"streams without endstream",
+			append([]byte("%PDF-1.7\n"), strings.Repeat("1 0 obj << >> stream\n", 20_000)...),
+			errPDFTooComplex,
+		},
+		{
+			"unclosed string in an object",
+			append([]byte("%PDF-1.7\n1 0 obj ("), strings.Repeat("2 0 obj (", 100_000)...),
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		d, err := parsePDF(context.Background(), tt.data)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+		if d != nil && d.lexed > maxPDFLex+len(tt.data) {
+			t.Errorf("%s: lexed %d bytes", tt.name, d.lexed)
+		}
+	}
+}
+
+func TestPDFPagesSharedKids(t *testing.T) {
+	// Every level lists the next twice: 2^30 paths lead to the one page.
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>"}
+	for i := 2; i < 32; i++ {
+		objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R %[1]d 0 R] >>", i+1))
+	}
+	objects = append(objects, "<< /Type /Page >>")
+	d, err := parsePDF(context.Background(), buildPDF(0, objects...))
+	if err != nil {
+		t.Fatalf("parsePDF: %v", err)
+	}
+	if pages := d.pages(); len(pages) != 1 {
+		t.Errorf("got %d pages, want 1", len(pages))
+	}
+}
+
+func TestPDFToUnicodeLimit(t *testing.T) {
+	d := &pdfDoc{ctx: context.Background()}
+	cmap := d.toUnicode([]byte(strings.Repeat("1 beginbfrange <0000> <FFFF> <0041> endbfrange\n", 100)))
+	if d.mapped > maxPDFCMap+1 || len(cmap) != 0x10000 {
+		t.Errorf("mapped %d entries into %d codes", d.mapped, len(cmap))
+	}
+	if d.err() != errPDFTooComplex {
+		t.Errorf("got %v, want %v", d.err(), errPDFTooComplex)
+	}
+}
+
+func TestPDFExtractorRecovers(t *testing.T) {
+	// The parser panics on its first context check; the panic must come
+	// back as an error.
+	var ctx context.Context
+	_, err := PDFExtractor{}.ExtractContext(ctx, Document{URL: "https://example.com/a.pdf", HTML: samplePDF()})
+	if classify(err).Code != internal.ErrExtractionEmpty {
+		t.Errorf("got %v, want an extraction_empty error", err)
+	}
+}
+
+func FuzzParsePDF(f *testing.F) {
+	f.Add(samplePDF())
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /A (unclosed"))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Type /ObjStm /N 9 /First 4 >> stream\n1 0 2 0 (x) endstream"))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Pages 2 0 R >> 2 0 obj << /Kids [2 0 R 1 0 R] >> trailer << /Root 1 0 R >>"))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Length 99999999999999999999 >>\nstream\nxx\nendstream"))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Type /ObjStm /N 1 /First 99999999999999999999 /Length 7 >>\nstream\n1 0 (x)\nendstream"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Parse without the extractor's recover, so a panic fails the test.
+		if d, err := parsePDF(context.Background(), data); err == nil {
+			for _, page := range d.pages() {
+				d.pageLines(page)
+			}
+		}
+		r, err := PDFExtractor{}.Extract(Document{URL: "https://example.com/a.pdf", HTML: data})
+		if err == nil && r.Content == "" {
+			t.Errorf("no error and no content")
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/val/autoga/internal"
+)
+
+// Handler scrapes a platform whose pages carry little article markup, such
+// as a video or social site, through the platform's public structured
+// endpoints instead of its HTML.
+type Handler interface {
+	// Hosts lists the domains the handler serves; subdomains match too.
+	Hosts() []string
+	// Handle scrapes u. ok is false when u is not a page the handler
+	// understands, such as a channel or search page; it is then scraped as
+	// an ordinary page. Like an Extractor, Handle may return partial
+	// metadata together with an error.
+	Handle(ctx context.Context, u *url.URL, format internal.Format) (r internal.ArticleResult, ok bool, err error)
+}
+
+// PlatformFetcher is how handlers reach platform endpoints. HTTPFetcher
+// implements it, so the requests share the host limiter, retries, robots.txt
+// rules and domain policies of page fetches.
+type PlatformFetcher interface {
+	FetchWith(ctx context.Context, url string, header http.Header, types ...string) (Page, error)
+}
+
+// Handlers is a registry of Handler implementations keyed by domain.
+type Handlers struct {
+	byDomain map[string]Handler
+}
+
+// NewHandlers builds a registry with the built-in YouTube, Reddit and
+// Telegram handlers, which reach the platforms through f. extra handlers
+// replace built-ins that serve the same domains. A nil f leaves out the
+// built-ins.
+func NewHandlers(f PlatformFetcher, extra ...Handler) *Handlers {
+	var list []Handler
+	if f != nil {
+		list = append(list,
+			NewYouTubeHandler(f, ""),
+			NewRedditHandler(f, ""),
+			NewTelegramHandler(f, ""),
+		)
+	}
+	h := &Handlers{byDomain: make(map[string]Handler)}
+	for _, hd := range append(list, extra...) {
+		for _, domain := range hd.Hosts() {
+			h.byDomain[hostKey(domain)] = hd
+		}
+	}
+	return h
+}
+
+// WithHandlers makes the Scraper pass URLs on the domains h serves to their
+// platform handler instead of fetching and extracting the page.
+func WithHandlers(h *Handlers) Option {
+	return func(s *Scraper) { s.handlers = h }
+}
+
+// lookup returns the handler for host or its closest parent domain, nil if
+// none. A nil *Handlers has none.
+func (h *Handlers) lookup(host string) Handler {
+	if h == nil {
+		return nil
+	}
+	for domain := hostKey(host); domain != ""; {
+		if hd, ok := h.byDomain[domain]; ok {
+			return hd
+		}
+		_, rest, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = rest
+	}
+	return nil
+}
+
+// handle scrapes clean with its platform handler. It returns false if no
+// handler takes the URL.
+func (h *Handlers) handle(ctx context.Context, clean string, format internal.Format) (internal.ArticleResult, bool) {
+	u, err := url.Parse(clean)
+	if err != nil {
+		return internal.ArticleResult{}, false
+	}
+	hd := h.lookup(u.Hostname())
+	if hd == nil {
+		return internal.ArticleResult{}, false
+	}
+	r, ok, err := hd.Handle(ctx, u, format)
+	if !ok {
+		return internal.ArticleResult{}, false
+	}
+	r.URL = clean
+	if err != nil {
+		r.Format = format
+		return withError(r, err), true
+	}
+	return r, true
+}
+
+// fetchPlatform fetches target through f with header, whose Accept names
+// the one media type wanted, and returns the body. The fetch's attempts and
+// limiter wait are added to r, which also gets the response's status and
+// media type.
+func fetchPlatform(ctx context.Context, f PlatformFetcher, target string, header http.Header, r *internal.ArticleResult) ([]byte, error) {
+	page, err := f.FetchWith(ctx, target, header, header.Get("Accept"))
+	r.Attempts += page.Attempts
+	r.LimiterWaitMS += page.LimiterWait.Milliseconds()
+	r.HTTPStatus = page.Status
+	if err != nil {
+		return nil, err
+	}
+	r.ContentType = mediaType(page.ContentType)
+	return page.Body, nil
+}
+
+// getJSON fetches target through f and decodes the JSON answer into v.
+func getJSON(ctx context.Context, f PlatformFetcher, target string, r *internal.ArticleResult, v any) error {
+	body, err := fetchPlatform(ctx, f, target, http.Header{"Accept": {"application/json"}}, r)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return errUnexpectedAnswer(target, err)
+	}
+	return nil
+}
+
+// errUnexpectedAnswer is the error for an endpoint answer that cannot be read.
+func errUnexpectedAnswer(target string, err error) error {
+	return &Error{
+		Code: internal.ErrExtractionEmpty,
+		Err:  fmt.Errorf("unexpected answer from %s: %w", target, err),
+	}
+}
+
+// endpointBase returns base without a trailing slash, or def if base is empty.
+func endpointBase(base, def string) string {
+	if base == "" {
+		return def
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// splitLines returns the non-empty lines of s, tidied, as paragraphs.
+func splitLines(s string) []string {
+	var paras []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if line = tidyInline(line); line != "" {
+			paras = append(paras, line)
+		}
+	}
+	return paras
+}
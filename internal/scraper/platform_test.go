@@ -0,0 +1,202 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// stubPlatform serves routes by path and records the requests it gets.
+type stubPlatform struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newStubPlatform(t *testing.T, routes map[string]http.HandlerFunc) *stubPlatform {
+	t.Helper()
+	s := &stubPlatform{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+		if h, ok := routes[r.URL.Path]; ok {
+			h(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// recorded returns the requests received so far.
+func (s *stubPlatform) recorded() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// stubFetcher is an HTTPFetcher that may reach the local stub servers.
+func stubFetcher(opts ...FetcherOption) *HTTPFetcher {
+	opts = append([]FetcherOption{WithNetGuard(NewNetGuard("127.0.0.1"))}, opts...)
+	return NewHTTPFetcher(5*time.Second, opts...)
+}
+
+func serveBody(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestYouTubeHandler(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/oembed": serveBody("application/json",
+			`{"title":"A video","author_name":"A channel","provider_name":"YouTube","thumbnail_url":"https://i.ytimg.com/vi/x/hq.jpg"}`),
+		"/watch": serveBody("text/html; charset=utf-8", `<html><script>var ytInitialPlayerResponse = {
+			"videoDetails":{"shortDescription":"First line.\nSecond line.","keywords":["go"]},
+			"microformat":{"playerMicroformatRenderer":{"publishDate":"2024-05-06","category":"Education"}}};var x = 1;</script></html>`),
+	})
+	limiter := NewHostLimiter(HostLimit{MaxConcurrency: 1, MinDelay: 50 * time.Millisecond}, nil)
+	h := NewYouTubeHandler(stubFetcher(WithHostLimiter(limiter)), srv.URL)
+
+	r, ok, err := h.Handle(context.Background(), mustParse(t, "https://youtu.be/dQw4w9WgXcQ"), internal.FormatText)
+	if !ok || err != nil {
+		t.Fatalf("Handle: %v, %v", ok, err)
+	}
+	if r.Title != "A video" || r.Byline != "A channel" || r.Section != "Education" ||
+		r.Content != "First line.\n\nSecond line." || !strings.HasPrefix(r.PublishedAt, "2024-05-06") {
+		t.Errorf("got %+v", r)
+	}
+	if r.Attempts != 2 || r.LimiterWaitMS == 0 {
+		t.Errorf("got %d attempts and %d ms limiter wait, want 2 and the watch page's wait", r.Attempts, r.LimiterWaitMS)
+	}
+	if reqs := srv.recorded(); len(reqs) != 2 || reqs[0].Header.Get("Accept") != "application/json" ||
+		reqs[1].Header.Get("Cookie") != "CONSENT=YES+; SOCS=CAI" {
+		t.Errorf("requests sent wrong headers")
+	}
+}
+
+func TestRedditHandlerRetries(t *testing.T) {
+	var calls int
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/comments/abc123.json": func(w http.ResponseWriter, r *http.Request) {
+			if calls++; calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			serveBody("application/json; charset=UTF-8", `[{"data":{"children":[{"kind":"t3","data":{
+				"title":"A post","selftext":"Body text.\n\nMore text.","author":"someone",
+				"subreddit_name_prefixed":"r/golang","permalink":"/r/golang/comments/abc123/a_post/",
+				"is_self":true,"created_utc":1700000000,"edited":false}}]}}]`)(w, r)
+		},
+	})
+	f := stubFetcher(WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}))
+	h := NewRedditHandler(f, srv.URL)
+
+	r, ok, err := h.Handle(context.Background(), mustParse(t, "https://www.reddit.com/r/golang/comments/abc123/a_post/"), internal.FormatText)
+	if !ok || err != nil {
+		t.Fatalf("Handle: %v, %v", ok, err)
+	}
+	if r.Title != "A post" || r.Byline != "u/someone" || r.Content != "Body text.\n\nMore text." ||
+		r.CanonicalURL != "https://www.reddit.com/r/golang/comments/abc123/a_post/" {
+		t.Errorf("got %+v", r)
+	}
+	if r.Attempts != 2 || r.HTTPStatus != http.StatusOK || r.ContentType != "application/json" {
+		t.Errorf("got %d attempts, status %d, type %q", r.Attempts, r.HTTPStatus, r.ContentType)
+	}
+}
+
+func TestTelegramHandler(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/s/gonews/42": serveBody("text/html", `<html><body>
+			<div class="tgme_widget_message" data-post="gonews/41"><div class="tgme_widget_message_text">Older</div></div>
+			<div class="tgme_widget_message" data-post="gonews/42">
+				<a class="tgme_widget_message_owner_name">Go News</a>
+				<div class="tgme_widget_message_text">Go 2 released<br>Read all about it.</div>
+				<span class="tgme_widget_message_date"><time datetime="2024-03-04T05:06:07+00:00"></time></span>
+			</div></body></html>`),
+	})
+	h := NewTelegramHandler(stubFetcher(), srv.URL)
+
+	r, ok, err := h.Handle(context.Background(), mustParse(t, "https://t.me/gonews/42"), internal.FormatText)
+	if !ok || err != nil {
+		t.Fatalf("Handle: %v, %v", ok, err)
+	}
+	if r.Title != "Go 2 released" || r.SiteName != "Go News" || r.Content != "Go 2 released\n\nRead all about it." ||
+		r.PublishedAt != "2024-03-04T05:06:07Z" {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestPlatformDomainPolicies(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/s/gonews/1": serveBody("text/html", `<div class="tgme_widget_message" data-post="gonews/1">
+			<div class="tgme_widget_message_text">Hello</div></div>`),
+	})
+	policies := NewPolicies(map[string]DomainPolicy{
+		"127.0.0.1": {UserAgent: "autoga-test", Headers: map[string]string{"X-Token": "secret"}},
+	})
+	h := NewTelegramHandler(stubFetcher(WithFetchPolicies(policies)), srv.URL)
+	if _, _, err := h.Handle(context.Background(), mustParse(t, "https://t.me/gonews/1"), internal.FormatText); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if req := srv.recorded()[0]; req.UserAgent() != "autoga-test" || req.Header.Get("X-Token") != "secret" {
+		t.Errorf("policy headers not sent: %v", req.Header)
+	}
+
+	skip := NewPolicies(map[string]DomainPolicy{"127.0.0.1": {Skip: true, Reason: "test"}})
+	h = NewTelegramHandler(stubFetcher(WithFetchPolicies(skip)), srv.URL)
+	_, _, err := h.Handle(context.Background(), mustParse(t, "https://t.me/gonews/1"), internal.FormatText)
+	if n := len(srv.recorded()); classify(err).Code != internal.ErrSkipped || n != 1 {
+		t.Errorf("got %v after %d requests, want a skip without a request", err, n)
+	}
+}
+
+func TestPlatformRobots(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/robots.txt":           serveBody("text/plain", "User-agent: *\nDisallow: /comments/\n"),
+		"/comments/abc123.json": serveBody("application/json", `[]`),
+	})
+	client := &http.Client{Transport: NewNetGuard("127.0.0.1").Transport()}
+	robots := NewRobotsChecker(client, "autoga", time.Hour)
+	h := NewRedditHandler(stubFetcher(WithRobots(robots)), srv.URL)
+
+	_, _, err := h.Handle(context.Background(), mustParse(t, "https://redd.it/abc123"), internal.FormatText)
+	if classify(err).Code != internal.ErrBlockedByRobots {
+		t.Errorf("got %v, want %s", err, internal.ErrBlockedByRobots)
+	}
+	if reqs := srv.recorded(); len(reqs) != 1 || reqs[0].URL.Path != "/robots.txt" {
+		t.Errorf("made %d requests, want only the one for robots.txt", len(reqs))
+	}
+}
+
+func TestPlatformRejectsOtherTypes(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/comments/abc123.json": serveBody("image/png", "\x89PNG"),
+	})
+	h := NewRedditHandler(stubFetcher(), srv.URL)
+	_, _, err := h.Handle(context.Background(), mustParse(t, "https://redd.it/abc123"), internal.FormatText)
+	if classify(err).Code != internal.ErrNotHTML {
+		t.Errorf("got %v, want %s", err, internal.ErrNotHTML)
+	}
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"strings"
+	"time"
+)
+
+// DomainPolicy is how one domain and its subdomains are treated.
+type DomainPolicy struct {
+	Skip      bool              // never fetch the domain
+	Allow     bool              // allowlist entry; once any exists, unlisted domains are skipped
+	Reason    string            // why the domain is skipped, reported in ArticleResult.SkipReason
+	Timeout   time.Duration     // per-attempt fetch timeout replacing the default
+	UserAgent string            // fixed User-Agent instead of the rotating pool
+	Headers   map[string]string // extra request headers
+}
+
+// Policies maps domains to their DomainPolicy. The most specific domain
+// wins. One set is shared by the Scraper, which skips domains, and the
+// HTTPFetcher, which applies the request settings.
+type Policies struct {
+	domains   map[string]DomainPolicy
+	allowOnly bool
+}
+
+// NewPolicies creates a policy set from per-domain entries.
+func NewPolicies(domains map[string]DomainPolicy) *Policies {
+	p := &Policies{domains: make(map[string]DomainPolicy, len(domains))}
+	for domain, dp := range domains {
+		p.domains[hostKey(domain)] = dp
+		if dp.Allow {
+			p.allowOnly = true
+		}
+	}
+	return p
+}
+
+// Lookup returns the policy for host and whether any entry matched.
+// A nil *Policies matches nothing.
+func (p *Policies) Lookup(host string) (DomainPolicy, bool) {
+	if p == nil {
+		return DomainPolicy{}, false
+	}
+	for domain := hostKey(host); domain != ""; {
+		if dp, ok := p.domains[domain]; ok {
+			return dp, true
+		}
+		_, rest, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = rest
+	}
+	return DomainPolicy{}, false
+}
+
+// skipReason returns why host must not be fetched, or "" if it may be.
+func (p *Policies) skipReason(host string) string {
+	dp, ok := p.Lookup(host)
+	switch {
+	case ok && dp.Skip:
+		if dp.Reason != "" {
+			return dp.Reason
+		}
+		return "blocked by domain policy"
+	case p != nil && p.allowOnly && !(ok && dp.Allow):
+		return "not in domain allowlist"
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestPoliciesLookup(t *testing.T) {
+	p := NewPolicies(map[string]DomainPolicy{
+		"Example.com":      {Timeout: time.Second},
+		"news.example.com": {Timeout: 2 * time.Second},
+		"com":              {Timeout: 3 * time.Second},
+	})
+	tests := []struct {
+		host string
+		want time.Duration
+		ok   bool
+	}{
+		{"example.com", time.Second, true},
+		{"WWW.EXAMPLE.COM", time.Second, true},
+		{"news.example.com", 2 * time.Second, true},
+		{"a.b.news.example.com", 2 * time.Second, true},
+		{"othernews.example.com", time.Second, true},
+		{"example.org", 0, false},
+		{"notexample.com", 3 * time.Second, true},
+	}
+	for _, tt := range tests {
+		dp, ok := p.Lookup(tt.host)
+		if ok != tt.ok || dp.Timeout != tt.want {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.host, dp.Timeout, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := (*Policies)(nil).Lookup("example.com"); ok {
+		t.Errorf("nil policies matched")
+	}
+}
+
+func TestPoliciesSkipReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies map[string]DomainPolicy
+		host     string
+		want     string
+	}{
+		{"no policies", nil, "example.com", ""},
+		{"skip with reason", map[string]DomainPolicy{"facebook.com": {Skip: true, Reason: "social network"}}, "m.facebook.com", "social network"},
+		{"skip without reason", map[string]DomainPolicy{"facebook.com": {Skip: true}}, "facebook.com", "blocked by domain policy"},
+		{"settings only", map[string]DomainPolicy{"example.com": {Timeout: time.Second}}, "example.com", ""},
+		{"allowlisted", map[string]DomainPolicy{"example.com": {Allow: true}}, "www.example.com", ""},
+		{"outside allowlist", map[string]DomainPolicy{"example.com": {Allow: true}}, "example.org", "not in domain allowlist"},
+		{"settings do not allowlist", map[string]DomainPolicy{"example.com": {Allow: true}, "example.org": {Timeout: time.Second}}, "example.org", "not in domain allowlist"},
+		{"skipped subdomain of allowlisted", map[string]DomainPolicy{"example.com": {Allow: true}, "ads.example.com": {Skip: true, Reason: "ads"}}, "ads.example.com", "ads"},
+		{"allowlisted subdomain", map[string]DomainPolicy{"example.com": {Skip: true}, "news.example.com": {Allow: true}}, "news.example.com", ""},
+	}
+	for _, tt := range tests {
+		var p *Policies
+		if tt.policies != nil {
+			p = NewPolicies(tt.policies)
+		}
+		if got := p.skipReason(tt.host); got != tt.want {
+			t.Errorf("%s: skipReason(%q) = %q, want %q", tt.name, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestFetchRedirectSkippedDomain(t *testing.T) {
+	outside := newServerOn(t, "127.0.0.2", serveBody("text/html", "<p>outside</p>"))
+	site := newServerOn(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, outside.URL+"/", http.StatusFound)
+			return
+		}
+		serveBody("text/html", "<p>inside</p>")(w, r)
+	}))
+	policies := NewPolicies(map[string]DomainPolicy{"127.0.0.1": {Allow: true}})
+	f := NewHTTPFetcher(5*time.Second, WithNetGuard(NewNetGuard("127.0.0.1", "127.0.0.2")), WithFetchPolicies(policies))
+
+	if _, err := f.Fetch(context.Background(), site.URL+"/stay"); err != nil {
+		t.Fatalf("allowlisted page: %v", err)
+	}
+	_, err := f.Fetch(context.Background(), site.URL+"/away")
+	if e := classify(err); err == nil || e.Code != internal.ErrSkipped || e.Retryable {
+		t.Errorf("redirect outside the allowlist: got %v, want %s", err, internal.ErrSkipped)
+	}
+}
@@ -0,0 +1,138 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned by Pool.Run when accepting a batch would exceed
+	// the queue limit.
+	ErrQueueFull = errors.New("scrape queue is full")
+	// ErrBatchTooLarge is returned by Pool.Run for a batch larger than the
+	// whole queue, which would never be accepted.
+	ErrBatchTooLarge = errors.New("batch exceeds the scrape queue size")
+)
+
+// Pool is a process-wide set of workers shared by every Scrape call.
+// Each call submits its tasks as one batch; workers take tasks from the
+// queued batches round-robin so a large request cannot starve small ones.
+type Pool struct {
+	mu       sync.Mutex
+	ready    *sync.Cond
+	batches  []*batch
+	next     int
+	pending  int
+	maxQueue int
+}
+
+type batch struct {
+	tasks []func()
+}
+
+// NewPool starts workers goroutines that serve at most maxQueue pending
+// tasks. A maxQueue of zero or less leaves the queue unbounded.
+func NewPool(workers, maxQueue int) *Pool {
+	p := &Pool{maxQueue: maxQueue}
+	p.ready = sync.NewCond(&p.mu)
+	for range max(workers, 1) {
+		go p.work()
+	}
+	return p
+}
+
+// Run queues tasks as one batch and blocks until all of them have finished.
+// The batch is rejected as a whole with ErrQueueFull if it does not fit, or
+// with ErrBatchTooLarge if it never could. Once ctx is done, tasks not yet
+// started are dropped; Run then waits for the running ones and returns
+// ctx.Err().
+func (p *Pool) Run(ctx context.Context, tasks []func()) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	if p.maxQueue > 0 && len(tasks) > p.maxQueue {
+		return ErrBatchTooLarge
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(tasks))
+	b := &batch{tasks: make([]func(), len(tasks))}
+	for i, task := range tasks {
+		b.tasks[i] = func() {
+			defer wg.Done()
+			if ctx.Err() == nil {
+				task()
+			}
+		}
+	}
+
+	p.mu.Lock()
+	if p.maxQueue > 0 && p.pending+len(tasks) > p.maxQueue {
+		p.mu.Unlock()
+		return ErrQueueFull
+	}
+	p.batches = append(p.batches, b)
+	p.pending += len(tasks)
+	p.mu.Unlock()
+	p.ready.Broadcast()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	p.drop(b, &wg)
+	<-done
+	return ctx.Err()
+}
+
+// drop removes the tasks of b that no worker has taken yet.
+func (p *Pool) drop(b *batch, wg *sync.WaitGroup) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.Index(p.batches, b)
+	if i < 0 {
+		return
+	}
+	p.batches = slices.Delete(p.batches, i, i+1)
+	if i < p.next {
+		p.next--
+	}
+	p.pending -= len(b.tasks)
+	for range b.tasks {
+		wg.Done()
+	}
+	b.tasks = nil
+}
+
+func (p *Pool) work() {
+	for {
+		p.mu.Lock()
+		for p.pending == 0 {
+			p.ready.Wait()
+		}
+		if p.next >= len(p.batches) {
+			p.next = 0
+		}
+		b := p.batches[p.next]
+		task := b.tasks[0]
+		b.tasks = b.tasks[1:]
+		p.pending--
+		if len(b.tasks) == 0 {
+			// Dropping the batch shifts the next one into p.next.
+			p.batches = append(p.batches[:p.next], p.batches[p.next+1:]...)
+		} else {
+			p.next++
+		}
+		p.mu.Unlock()
+
+		task()
+	}
+}
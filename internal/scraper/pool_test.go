@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolRejectsOversizedBatch(t *testing.T) {
+	p := NewPool(1, 2)
+	if err := p.Run(context.Background(), make([]func(), 3)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("got %v, want %v", err, ErrBatchTooLarge)
+	}
+}
+
+func TestPoolDropsCanceledTasks(t *testing.T) {
+	p := NewPool(1, 10)
+	release := make(chan struct{})
+	var ran atomic.Int32
+	tasks := []func(){func() { ran.Add(1); <-release }}
+	for range 4 {
+		tasks = append(tasks, func() { ran.Add(1) })
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- p.Run(ctx, tasks) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if n := ran.Load(); n != 1 {
+		t.Errorf("ran %d tasks, want only the one already running", n)
+	}
+
+	// The dropped tasks no longer count against the queue.
+	var done atomic.Bool
+	if err := p.Run(context.Background(), []func(){func() { done.Store(true) }}); err != nil || !done.Load() {
+		t.Errorf("got %v, done %v after the canceled batch", err, done.Load())
+	}
+}
@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+// DefaultRedditBase is the address Reddit's JSON listings are requested from.
+const DefaultRedditBase = "https://www.reddit.com"
+
+const extractorReddit = "reddit"
+
+// redditPostRe matches a post id.
+var redditPostRe = regexp.MustCompile(`^[a-z0-9]{4,12}$`)
+
+// redditRemoved are the bodies Reddit leaves in place of removed posts.
+var redditRemoved = map[string]bool{"[removed]": true, "[deleted]": true}
+
+// RedditHandler reads posts from the JSON listing Reddit serves when
+// ".json" is appended to a post's address.
+type RedditHandler struct {
+	fetcher PlatformFetcher
+	base    string
+}
+
+// NewRedditHandler creates a RedditHandler requesting base through f.
+// An empty base means DefaultRedditBase.
+func NewRedditHandler(f PlatformFetcher, base string) *RedditHandler {
+	return &RedditHandler{fetcher: f, base: endpointBase(base, DefaultRedditBase)}
+}
+
+func (*RedditHandler) Hosts() []string {
+	return []string{"reddit.com", "redd.it"}
+}
+
+// Handle scrapes links to a post and its comments. The post's text is the
+// content; a link post adds the address it shares.
+func (rd *RedditHandler) Handle(ctx context.Context, u *url.URL, format internal.Format) (internal.ArticleResult, bool, error) {
+	id := redditPostID(u)
+	if id == "" {
+		return internal.ArticleResult{}, false, nil
+	}
+	r := internal.ArticleResult{Format: format, SiteName: "Reddit"}
+
+	var listings []struct {
+		Data struct {
+			Children []struct {
+				Kind string     `json:"kind"`
+				Data redditPost `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	target := rd.base + "/comments/" + id + ".json?raw_json=1&limit=1"
+	if err := getJSON(ctx, rd.fetcher, target, &r, &listings); err != nil {
+		return r, true, err
+	}
+	if len(listings) == 0 || len(listings[0].Data.Children) == 0 || listings[0].Data.Children[0].Kind != "t3" {
+		return r, true, errUnexpectedAnswer(target, errNoPost)
+	}
+	post := listings[0].Data.Children[0].Data
+
+	r.Title = post.Title
+	if post.Author != "" && !redditRemoved[post.Author] {
+		r.Byline = "u/" + post.Author
+	}
+	r.Section = post.Subreddit
+	if post.Permalink != "" {
+		r.CanonicalURL = DefaultRedditBase + post.Permalink
+		r.FinalURL = r.CanonicalURL
+	}
+	r.PublishedAt = unixTime(post.CreatedUTC)
+	if edited, ok := post.Edited.(float64); ok {
+		r.ModifiedAt = unixTime(edited)
+	}
+	if len(post.Preview.Images) > 0 {
+		r.ImageURL = post.Preview.Images[0].Source.URL
+	} else if img, ok := httpURL(post.Thumbnail); ok {
+		r.ImageURL = img
+	}
+	if post.Flair != "" {
+		r.Keywords = []string{post.Flair}
+	}
+
+	var paras []string
+	if text := strings.TrimSpace(post.Selftext); !redditRemoved[text] {
+		for _, block := range blankLineRe.Split(strings.ReplaceAll(text, "\r\n", "\n"), -1) {
+			if p := tidyInline(block); p != "" {
+				paras = append(paras, p)
+			}
+		}
+	}
+	r.Excerpt = firstLine(paras)
+	if link, ok := httpURL(post.URL); ok && !post.IsSelf {
+		paras = append(paras, link)
+	}
+
+	r, err := finishDocument(r, paragraphCandidate(extractorReddit, paras, r.Format))
+	return r, true, err
+}
+
+// redditPost is the part of a post ("t3") listing entry that is used.
+type redditPost struct {
+	Title      string  `json:"title"`
+	Selftext   string  `json:"selftext"`
+	Author     string  `json:"author"`
+	Subreddit  string  `json:"subreddit_name_prefixed"`
+	Permalink  string  `json:"permalink"`
+	URL        string  `json:"url"`
+	IsSelf     bool    `json:"is_self"`
+	CreatedUTC float64 `json:"created_utc"`
+	Edited     any     `json:"edited"` // false, or when the post was last edited
+	Flair      string  `json:"link_flair_text"`
+	Thumbnail  string  `json:"thumbnail"`
+	Preview    struct {
+		Images []struct {
+			Source struct {
+				URL string `json:"url"`
+			} `json:"source"`
+		} `json:"images"`
+	} `json:"preview"`
+}
+
+// errNoPost reports a listing without a post.
+var errNoPost = errors.New("no post in listing")
+
+// redditPostID returns the id of the post u links to, "" if it is not a
+// post link: /r/<sub>/comments/<id>/..., /comments/<id> or redd.it/<id>.
+func redditPostID(u *url.URL) string {
+	var id string
+	if matchesDomain(u.Hostname(), "redd.it") {
+		id, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	} else if _, rest, found := strings.Cut(u.Path, "/comments/"); found {
+		id, _, _ = strings.Cut(rest, "/")
+	}
+	id = strings.ToLower(id)
+	if !redditPostRe.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// unixTime formats seconds since the epoch as RFC 3339, "" for zero.
+func unixTime(sec float64) string {
+	if sec <= 0 {
+		return ""
+	}
+	return time.Unix(int64(sec), 0).UTC().Format(time.RFC3339)
+}
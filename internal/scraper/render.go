@@ -0,0 +1,367 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"github.com/val/autoga/internal"
+)
+
+// render converts extracted article markup into the requested format.
+// FormatTextFlat is the text rendering collapsed onto one line, without
+// list markers.
+func render(n *html.Node, format internal.Format) string {
+	switch format {
+	case internal.FormatTextFlat:
+		return tidyInline(strings.Join((&textRenderer{flat: true}).blocks(n), " "))
+	case internal.FormatHTML:
+		var b strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeSafeHTML(&b, c)
+		}
+		return strings.TrimSpace(b.String())
+	case internal.FormatMarkdown:
+		return strings.Join((&textRenderer{markdown: true}).blocks(n), "\n\n")
+	default:
+		return strings.Join((&textRenderer{}).blocks(n), "\n\n")
+	}
+}
+
+// blockTags are elements that start a new paragraph in text output.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true,
+	"details": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"tbody": true, "td": true, "tfoot": true, "th": true, "thead": true, "tr": true,
+	"ul": true,
+}
+
+// skipTags never contribute text.
+var skipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"iframe": true, "object": true, "embed": true, "svg": true, "form": true,
+	"button": true, "select": true, "textarea": true, "input": true,
+}
+
+// textRenderer turns an HTML tree into paragraphs of plain text or Markdown.
+type textRenderer struct {
+	markdown bool
+	flat     bool // list items carry no markers
+}
+
+// blocks renders the children of n as a list of paragraphs. Runs of inline
+// children are merged into one paragraph; block children are rendered recursively.
+func (r *textRenderer) blocks(n *html.Node) []string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if p := tidyParagraph(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && blockTags[c.Data] {
+			flush()
+			out = append(out, r.block(c)...)
+			continue
+		}
+		r.inline(&inline, c)
+	}
+	flush()
+	return out
+}
+
+func (r *textRenderer) block(n *html.Node) []string {
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		var b strings.Builder
+		r.inlineChildren(&b, n)
+		text := tidyParagraph(b.String())
+		if text == "" {
+			return nil
+		}
+		if r.markdown {
+			text = strings.Repeat("#", int(n.Data[1]-'0')) + " " + strings.ReplaceAll(text, "\n", " ")
+		}
+		return []string{text}
+	case "ul", "ol":
+		if list := r.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case "blockquote":
+		inner := r.blocks(n)
+		if !r.markdown || len(inner) == 0 {
+			return inner
+		}
+		return []string{prefixLines(strings.Join(inner, "\n\n"), "> ", ">")}
+	case "pre":
+		text := strings.Trim(textContent(n), "\n")
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		if r.markdown {
+			text = "```\n" + text + "\n```"
+		}
+		return []string{text}
+	case "hr":
+		if r.markdown {
+			return []string{"---"}
+		}
+		return nil
+	case "tr":
+		var cells []string
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+				cells = append(cells, strings.ReplaceAll(strings.Join(r.blocks(c), " "), "\n", " "))
+			}
+		}
+		if row := strings.TrimSpace(strings.Join(cells, " | ")); row != "" {
+			return []string{row}
+		}
+		return nil
+	case "table", "thead", "tbody", "tfoot":
+		// Keep rows of one table together as a single paragraph.
+		if rows := r.blocks(n); len(rows) > 0 {
+			return []string{strings.Join(rows, "\n")}
+		}
+		return nil
+	default:
+		return r.blocks(n)
+	}
+}
+
+// list renders ul/ol items one per line; continuation paragraphs are indented.
+func (r *textRenderer) list(n *html.Node) string {
+	var lines []string
+	i := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.Data != "li" {
+			continue
+		}
+		i++
+		marker := "- "
+		switch {
+		case r.flat:
+			marker = ""
+		case n.Data == "ol":
+			marker = fmt.Sprintf("%d. ", i)
+		}
+		item := strings.Join(r.blocks(c), "\n")
+		if item == "" {
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		lines = append(lines, marker+prefixLines(item, indent, "")[len(indent):])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *textRenderer) inlineChildren(b *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.inline(b, c)
+	}
+}
+
+func (r *textRenderer) inline(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skipTags[n.Data] {
+		return
+	}
+
+	switch n.Data {
+	case "br":
+		b.WriteString("\n")
+		return
+	case "img":
+		if r.markdown {
+			if src, ok := safeURL(attr(n, "src")); ok {
+				fmt.Fprintf(b, "![%s](%s)", tidyInline(attr(n, "alt")), src)
+			}
+		}
+		return
+	}
+
+	if !r.markdown {
+		r.inlineChildren(b, n)
+		return
+	}
+
+	var inner strings.Builder
+	r.inlineChildren(&inner, n)
+	raw := inner.String()
+	text := tidyInline(raw)
+	if text == "" {
+		b.WriteString(raw)
+		return
+	}
+	switch n.Data {
+	case "a":
+		if href, ok := safeURL(attr(n, "href")); ok && !strings.HasPrefix(href, "#") {
+			text = "[" + text + "](" + href + ")"
+		}
+	case "strong", "b":
+		text = "**" + text + "**"
+	case "em", "i":
+		text = "*" + text + "*"
+	case "code":
+		text = "`" + text + "`"
+	default:
+		b.WriteString(raw)
+		return
+	}
+	// Markers must hug the text, so move surrounding whitespace outside them.
+	if strings.TrimLeftFunc(raw, unicode.IsSpace) != raw {
+		b.WriteString(" ")
+	}
+	b.WriteString(text)
+	if strings.TrimRightFunc(raw, unicode.IsSpace) != raw {
+		b.WriteString(" ")
+	}
+}
+
+// tidyParagraph collapses whitespace within each line of s and drops blank lines.
+func tidyParagraph(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = tidyInline(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tidyInline collapses all whitespace runs, including newlines, to single spaces.
+func tidyInline(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// prefixLines prefixes every non-empty line of s with prefix and empty lines with blank.
+func prefixLines(s, prefix, blank string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blank
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// safeURLSchemes are the schemes kept in rendered links and images;
+// relative URLs, which have none, are kept too.
+var safeURLSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// safeURL returns u without the control characters browsers ignore in URLs,
+// and whether the result is a non-empty URL of a safe scheme. Cleaning
+// first keeps "java\tscript:" from passing as a relative URL.
+func safeURL(u string) (string, bool) {
+	u = strings.TrimFunc(u, func(r rune) bool { return r <= ' ' })
+	u = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	if u == "" {
+		return "", false
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "" && !safeURLSchemes[parsed.Scheme]) {
+		return "", false
+	}
+	return u, true
+}
+
+// safeTags are kept by the HTML sanitizer along with their allowed attributes.
+// Elements not listed here are unwrapped; elements in skipTags are dropped.
+var safeTags = map[string][]string{
+	"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": nil,
+	"br": nil, "caption": nil, "code": nil, "dd": nil, "del": nil, "div": nil,
+	"dl": nil, "dt": nil, "em": nil, "figcaption": nil, "figure": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil,
+	"i": nil, "img": {"src", "alt", "title", "width", "height"}, "ins": nil,
+	"li": nil, "ol": nil, "p": nil, "pre": nil, "q": nil, "s": nil, "small": nil,
+	"span": nil, "strong": nil, "sub": nil, "sup": nil, "table": nil,
+	"tbody": nil, "td": {"colspan", "rowspan"}, "tfoot": nil,
+	"th": {"colspan", "rowspan"}, "thead": nil, "tr": nil, "u": nil, "ul": nil,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// writeSafeHTML writes n with only allowlisted tags and attributes.
+func writeSafeHTML(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if skipTags[n.Data] {
+		return
+	}
+	allowed, ok := safeTags[n.Data]
+	if !ok {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeSafeHTML(b, c)
+		}
+		return
+	}
+
+	b.WriteString("<" + n.Data)
+	for _, key := range allowed {
+		val := attr(n, key)
+		if key == "href" || key == "src" {
+			val, _ = safeURL(val)
+		}
+		if val == "" {
+			continue
+		}
+		fmt.Fprintf(b, ` %s="%s"`, key, html.EscapeString(val))
+	}
+	b.WriteString(">")
+	if voidTags[n.Data] {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeSafeHTML(b, c)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
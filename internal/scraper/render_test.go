@@ -0,0 +1,103 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"https://example.com/a?b=c", "https://example.com/a?b=c", true},
+		{"HTTP://example.com/", "HTTP://example.com/", true},
+		{"mailto:editor@example.com", "mailto:editor@example.com", true},
+		{"/relative/path", "/relative/path", true},
+		{"../up#frag", "../up#frag", true},
+		{"//cdn.example.com/img.png", "//cdn.example.com/img.png", true},
+		{"#section", "#section", true},
+		{" \x01https://example.com/\x00", "https://example.com/", true},
+		{"", "", false},
+		{" \t\n", "", false},
+		{"javascript:alert(1)", "", false},
+		{"JavaScript:alert(1)", "", false},
+		{"java\tscript:alert(1)", "", false},
+		{"java\nscript:alert(1)", "", false},
+		{"\x01javascript:alert(1)", "", false},
+		{"\x7fjavascript:alert(1)", "", false},
+		{"java script:alert(1)", "", false},
+		{"vbscript:msgbox", "", false},
+		{"data:text/html;base64,PHNjcmlwdD4=", "", false},
+		{"file:///etc/passwd", "", false},
+		{"ftp://example.com/f", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := safeURL(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("safeURL(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRenderSafeHTML(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"allowed tags and attributes",
+			`<p class="x" onclick="evil()">Hi <a href="https://example.com/" rel="x">there</a></p>`,
+			`<p>Hi <a href="https://example.com/">there</a></p>`,
+		},
+		{
+			"script and form dropped, unknown tags unwrapped",
+			`<p>a<script>alert(1)</script><custom>b</custom></p><form><input></form>`,
+			`<p>ab</p>`,
+		},
+		{
+			"entity-encoded tab in scheme",
+			`<a href="java&#x09;script:alert(1)">x</a>`,
+			`<a>x</a>`,
+		},
+		{
+			"entity-encoded scheme",
+			`<a href="&#106;avascript:alert(1)">x</a>`,
+			`<a>x</a>`,
+		},
+		{
+			"leading control character",
+			`<img src="&#1;javascript:alert(1)" alt="pic">`,
+			`<img alt="pic">`,
+		},
+		{
+			"cleaned URL kept",
+			`<img src=" https://example.com/a&#10;b.png" alt="pic">`,
+			`<img src="https://example.com/ab.png" alt="pic">`,
+		},
+		{
+			"attribute values escaped",
+			`<a href="/q?a=1&amp;b=&quot;2&quot;" title="&lt;t&gt;">x</a>`,
+			`<a href="/q?a=1&amp;b=&#34;2&#34;" title="&lt;t&gt;">x</a>`,
+		},
+		{
+			"text escaped",
+			`<p>&lt;script&gt;</p>`,
+			`<p>&lt;script&gt;</p>`,
+		},
+	}
+	for _, tt := range tests {
+		body := parseTestHTML(t, tt.in)
+		if got := render(body, internal.FormatHTML); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownLinks(t *testing.T) {
+	body := parseTestHTML(t, `<p><a href="https://example.com/">safe</a> <a href="java&#9;script:x">unsafe</a> <a href="#top">anchor</a> <img src="data:image/png;base64,AA" alt="inline"></p>`)
+	want := "[safe](https://example.com/) unsafe anchor"
+	if got := render(body, internal.FormatMarkdown); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
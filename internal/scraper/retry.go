@@ -0,0 +1,98 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how HTTPFetcher retries transient failures.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; <= 1 disables retries
+	BaseDelay   time.Duration // backoff before the second attempt, doubled for each further one
+	MaxDelay    time.Duration // cap on a single backoff, including one requested via Retry-After
+}
+
+// statusError reports a non-200 response.
+type statusError struct {
+	status     int
+	url        string
+	retryAfter time.Duration // parsed Retry-After header, zero if absent
+	challenge  bool          // response is a bot-protection challenge
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s", e.status, e.url)
+}
+
+// retryable reports whether err is worth another attempt: timeouts,
+// dropped connections and 429/502/503/504 responses.
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		switch se.status {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// delay returns the wait before attempt+1 and whether a retry is possible at
+// all. Retry-After is honoured as a lower bound; if it exceeds MaxDelay or
+// the time left before ctx's deadline, the retry is abandoned.
+func (p RetryPolicy) delay(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || (p.MaxDelay > 0 && backoff > p.MaxDelay) {
+		backoff = p.MaxDelay
+	}
+	// Jitter into [backoff/2, backoff] so parallel callers spread out.
+	if half := backoff / 2; half > 0 {
+		backoff = half + rand.N(half+1)
+	}
+
+	var se *statusError
+	if errors.As(err, &se) && se.retryAfter > backoff {
+		if p.MaxDelay > 0 && se.retryAfter > p.MaxDelay {
+			return 0, false
+		}
+		backoff = se.retryAfter
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+		return 0, false
+	}
+	return backoff, true
+}
+
+// parseRetryAfter accepts both delay-seconds and HTTP-date forms.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
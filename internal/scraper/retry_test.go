@@ -0,0 +1,43 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayDeadline(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: time.Second}
+	err := &statusError{status: http.StatusServiceUnavailable}
+
+	if _, ok := p.delay(context.Background(), 1, err); !ok {
+		t.Errorf("gave up without a deadline")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if wait, ok := p.delay(ctx, 1, err); ok {
+		t.Errorf("waits %v past a deadline 50ms away", wait)
+	}
+}
+
+func TestFetchGivesUpBeforeDeadline(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/busy": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})
+	f := stubFetcher(WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	page, err := f.Fetch(ctx, srv.URL+"/busy")
+	if e := classify(err); err == nil || !e.Retryable {
+		t.Errorf("got %v, want a retryable error", err)
+	}
+	if page.Attempts != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("made %d attempts in %v, want one and an early give-up", page.Attempts, time.Since(start))
+	}
+}
@@ -0,0 +1,330 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/val/autoga/internal"
+
+	"github.com/val/autoga/internal/useragent"
+)
+
+// maxRobotsBytes is how much of a robots.txt is parsed, per RFC 9309.
+const maxRobotsBytes = 500 * 1024
+
+// robotsUnavailableTTL is how long a robots.txt that failed with a server
+// error is treated as disallowing everything before it is requested again.
+const robotsUnavailableTTL = 10 * time.Minute
+
+// RobotsChecker enforces publishers' robots.txt rules (RFC 9309) and their
+// non-standard Crawl-delay. Rules are fetched once per origin and cached.
+type RobotsChecker struct {
+	agent  string
+	client *http.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	ready   chan struct{} // closed once rules or err are set
+	rules   robotsRules
+	err     error
+	expires time.Time
+	next    time.Time // earliest start of the next request under Crawl-delay
+}
+
+// NewRobotsChecker creates a checker that follows the rules for the
+// product token agent (e.g. "autoga"), falling back to the "*" group.
+// Rules are fetched with client and kept for ttl.
+func NewRobotsChecker(client *http.Client, agent string, ttl time.Duration) *RobotsChecker {
+	return &RobotsChecker{
+		agent:  strings.ToLower(agent),
+		client: client,
+		ttl:    ttl,
+		hosts:  make(map[string]*robotsEntry),
+	}
+}
+
+// Allowed reports whether u may be fetched. It returns an *Error with code
+// ErrBlockedByRobots when it may not, and a plain error when robots.txt
+// could not be requested at all.
+func (c *RobotsChecker) Allowed(ctx context.Context, u *url.URL) error {
+	e, err := c.entry(ctx, u)
+	if err != nil {
+		return err
+	}
+	return e.check(u)
+}
+
+// Wait checks u like Allowed and then blocks until the origin's Crawl-delay
+// since the previous request has passed. It returns how long it waited. A
+// wait that would outlast ctx's deadline fails at once, without taking the
+// origin's next slot.
+func (c *RobotsChecker) Wait(ctx context.Context, u *url.URL) (time.Duration, error) {
+	e, err := c.entry(ctx, u)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.check(u); err != nil || e.rules.crawlDelay <= 0 {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	start := now
+	if e.next.After(now) {
+		start = e.next
+	}
+	if deadline, ok := ctx.Deadline(); ok && start.After(deadline) {
+		c.mu.Unlock()
+		return 0, errPastDeadline(u.Hostname())
+	}
+	e.next = start.Add(e.rules.crawlDelay)
+	c.mu.Unlock()
+
+	wait := start.Sub(now)
+	if wait <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return time.Since(now), ctx.Err()
+	case <-timer.C:
+		return wait, nil
+	}
+}
+
+func (e *robotsEntry) check(u *url.URL) error {
+	if e.rules.allowed(robotsPath(u)) {
+		return nil
+	}
+	return &Error{
+		Code: internal.ErrBlockedByRobots,
+		Err:  fmt.Errorf("%s is disallowed by robots.txt", u),
+	}
+}
+
+// entry returns the loaded rules for u's origin, fetching them if needed.
+// Concurrent callers for one origin share a single robots.txt request.
+func (c *RobotsChecker) entry(ctx context.Context, u *url.URL) (*robotsEntry, error) {
+	origin := u.Scheme + "://" + strings.ToLower(u.Host)
+
+	c.mu.Lock()
+	e, ok := c.hosts[origin]
+	if ok {
+		select {
+		case <-e.ready:
+			ok = time.Now().Before(e.expires)
+		default:
+		}
+	}
+	if !ok {
+		c.prune()
+		e = &robotsEntry{ready: make(chan struct{})}
+		c.hosts[origin] = e
+		// The fetch outlives this caller's context so other waiters are not
+		// failed by its cancellation.
+		go func() {
+			rules, ttl, err := c.fetch(context.WithoutCancel(ctx), origin)
+			c.mu.Lock()
+			e.rules, e.err, e.expires = rules, err, time.Now().Add(ttl)
+			if err != nil && c.hosts[origin] == e {
+				delete(c.hosts, origin)
+			}
+			close(e.ready)
+			c.mu.Unlock()
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prune drops expired entries once the cache is large. Must hold c.mu.
+func (c *RobotsChecker) prune() {
+	if len(c.hosts) < maxIdleHosts {
+		return
+	}
+	now := time.Now()
+	for k, e := range c.hosts {
+		select {
+		case <-e.ready:
+			if now.After(e.expires) && now.After(e.next) {
+				delete(c.hosts, k)
+			}
+		default:
+		}
+	}
+}
+
+// fetch requests origin's robots.txt. Per RFC 9309 a 4xx means no rules and
+// a 5xx means everything is disallowed until the server recovers.
+func (c *RobotsChecker) fetch(ctx context.Context, origin string) (robotsRules, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsRules{}, 0, fmt.Errorf("build robots.txt request: %w", err)
+	}
+	req.Header.Set("User-Agent", useragent.Next())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return robotsRules{}, 0, fmt.Errorf("fetch robots.txt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		drain(resp.Body)
+	}
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return robotsRules{disallowAll: true}, robotsUnavailableTTL, nil
+	case resp.StatusCode != http.StatusOK:
+		return robotsRules{}, c.ttl, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+	if err != nil {
+		return robotsRules{}, 0, fmt.Errorf("read robots.txt: %w", err)
+	}
+	return parseRobots(body, c.agent), c.ttl, nil
+}
+
+// robotsRules is the group of a robots.txt that applies to one agent.
+type robotsRules struct {
+	disallowAll bool
+	rules       []robotsRule
+	crawlDelay  time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int // pattern length; the longest matching rule wins
+	pattern *regexp.Regexp
+}
+
+// allowed applies the longest matching rule to path; on a tie allow wins.
+func (r robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if rule.length < best || !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || rule.allow {
+			best, allow = rule.length, rule.allow
+		}
+	}
+	return allow
+}
+
+// parseRobots returns the rules for agent: all groups naming it are merged,
+// and the "*" groups apply only if none does.
+func parseRobots(body []byte, agent string) robotsRules {
+	var own, star robotsRules
+	var ownFound bool
+	var forOwn, forAny, inAgents bool
+
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), maxRobotsBytes)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				forOwn, forAny = false, false
+				inAgents = true
+			}
+			token := strings.ToLower(value)
+			switch {
+			case token == "*":
+				forAny = true
+			case token == agent:
+				forOwn, ownFound = true, true
+			}
+			continue
+		}
+		inAgents = false
+
+		var target []*robotsRules
+		if forOwn {
+			target = append(target, &own)
+		}
+		if forAny {
+			target = append(target, &star)
+		}
+		for _, r := range target {
+			switch key {
+			case "allow", "disallow":
+				if value == "" {
+					continue // an empty Disallow allows everything
+				}
+				r.rules = append(r.rules, robotsRule{
+					allow:   key == "allow",
+					length:  len(value),
+					pattern: robotsPattern(value),
+				})
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					r.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+	if ownFound {
+		return own
+	}
+	return star
+}
+
+// robotsPattern compiles a path pattern: "*" matches any sequence and a
+// trailing "$" anchors the end.
+func robotsPattern(p string) *regexp.Regexp {
+	anchored := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	parts := strings.Split(p, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// robotsPath is the part of u matched against robots.txt rules.
+func robotsPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return p
+}
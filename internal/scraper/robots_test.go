@@ -0,0 +1,152 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/val/autoga/internal"
+)
+
+func TestParseRobotsGroups(t *testing.T) {
+	const body = `
+User-agent: *
+Disallow: /private/
+Crawl-delay: 7
+
+# Consecutive agent lines share one group.
+User-agent: otherbot
+User-Agent: AutoGA
+Disallow: /drafts/
+
+User-agent: googlebot
+Disallow: /
+
+user-agent: autoga   # groups naming the agent are merged
+Disallow: /tmp/
+Crawl-delay: 0.5
+`
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"autoga", "/drafts/1", false},
+		{"autoga", "/tmp/x", false},
+		{"autoga", "/private/x", true}, // the * group does not apply once one names the agent
+		{"otherbot", "/drafts/1", false},
+		{"otherbot", "/tmp/x", true},
+		{"somebot", "/private/x", false},
+		{"somebot", "/drafts/1", true},
+		{"googlebot", "/", false},
+	}
+	for _, tt := range tests {
+		if got := parseRobots([]byte(body), tt.agent).allowed(tt.path); got != tt.want {
+			t.Errorf("%s %s: allowed = %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+	if d := parseRobots([]byte(body), "autoga").crawlDelay; d != 500*time.Millisecond {
+		t.Errorf("autoga crawl delay %v, want 500ms", d)
+	}
+	if d := parseRobots([]byte(body), "somebot").crawlDelay; d != 7*time.Second {
+		t.Errorf("* crawl delay %v, want 7s", d)
+	}
+}
+
+func TestRobotsRulesAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		path  string
+		want  bool
+	}{
+		{"no rules", "", "/a", true},
+		{"empty disallow", "Disallow:", "/a", true},
+		{"prefix", "Disallow: /a", "/abc", false},
+		{"no match", "Disallow: /a", "/b", true},
+		{"longer allow wins", "Disallow: /a\nAllow: /a/b", "/a/b/c", true},
+		{"longer disallow wins", "Allow: /a\nDisallow: /a/b", "/a/b/c", false},
+		{"longer rule wins regardless of order", "Allow: /a/b\nDisallow: /a", "/a/b", true},
+		{"allow wins a tie", "Disallow: /a\nAllow: /a", "/a", true},
+		{"allow wins a tie in either order", "Allow: /a\nDisallow: /a", "/a", true},
+		{"wildcard", "Disallow: /*.pdf", "/files/doc.pdf", false},
+		{"wildcard no match", "Disallow: /*.pdf", "/files/doc.html", true},
+		{"anchored", "Disallow: /*.pdf$", "/doc.pdf?x=1", true},
+		{"anchored match", "Disallow: /*.pdf$", "/doc.pdf", false},
+		{"query", "Disallow: /*?print=", "/a?print=1", false},
+		{"regexp metacharacters are literal", "Disallow: /a.b", "/axb", true},
+		{"root", "Disallow: /", "/anything", false},
+	}
+	for _, tt := range tests {
+		rules := parseRobots([]byte("User-agent: *\n"+tt.rules), "autoga")
+		if got := rules.allowed(tt.path); got != tt.want {
+			t.Errorf("%s: allowed(%q) = %v, want %v", tt.name, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNotFound, true},
+		{http.StatusForbidden, true},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte("User-agent: *\nDisallow: /a\n"))
+		}))
+		c := NewRobotsChecker(srv.Client(), "autoga", time.Hour)
+		err := c.Allowed(context.Background(), mustParse(t, srv.URL+"/a"))
+		if got := err == nil; got != tt.want {
+			t.Errorf("status %d: got %v, want allowed %v", tt.status, err, tt.want)
+		} else if err != nil && classify(err).Code != internal.ErrBlockedByRobots {
+			t.Errorf("status %d: got %v, want %s", tt.status, err, internal.ErrBlockedByRobots)
+		}
+		srv.Close()
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	srv := newStubPlatform(t, map[string]http.HandlerFunc{
+		"/robots.txt": serveBody("text/plain", "User-agent: *\nCrawl-delay: 0.1\n"),
+	})
+	c := NewRobotsChecker(srv.Client(), "autoga", time.Hour)
+	u := mustParse(t, srv.URL+"/a")
+
+	if waited, err := c.Wait(context.Background(), u); err != nil || waited != 0 {
+		t.Fatalf("first request waited %v, %v", waited, err)
+	}
+	start := time.Now()
+	if _, err := c.Wait(context.Background(), u); err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("second request started after %v, want the crawl delay", elapsed)
+	}
+
+	// A slot past the caller's deadline fails at once without taking it.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Wait(ctx, u); classify(err).Code != internal.ErrTimeout || ctx.Err() != nil {
+		t.Errorf("got %v after the context ended with %v, want %s at once", err, ctx.Err(), internal.ErrTimeout)
+	}
+	start = time.Now()
+	if _, err := c.Wait(context.Background(), u); err != nil {
+		t.Fatalf("third request: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("third request waited %v, want one crawl delay", elapsed)
+	}
+	if reqs := srv.recorded(); len(reqs) != 1 {
+		t.Errorf("fetched robots.txt %d times, want once", len(reqs))
+	}
+}
@@ -2,61 +2,297 @@ package scraper
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"net/url"
 
 	"github.com/val/autoga/internal"
 )
 
 // Scraper orchestrates concurrent fetching and extraction of articles.
 type Scraper struct {
-	fetcher    Fetcher
-	extractor  Extractor
-	maxWorkers int
+	fetcher   Fetcher
+	extractor Extractor
+	pool      *Pool
+	cache     Cache
+	unwrap    *Unwrappers
+	normalize *Normalizer
+	policies  *Policies
+	handlers  *Handlers
+	flights   flightGroup
+	aliases   aliasIndex
+
+	minConfidence float64 // below it alternates are tried; zero disables them
+	archives      []Archive
+	maxPages      int // pages of a paginated article merged; below 2 only the first
+}
+
+// Option configures optional Scraper behaviour.
+type Option func(*Scraper)
+
+// WithCache serves repeated URLs from c and stores successful results in it.
+func WithCache(c Cache) Option {
+	return func(s *Scraper) { s.cache = c }
+}
+
+// WithUnwrappers sets the registry used to unwrap redirector and shortener
+// URLs. Without it only offline unwrapping (Google redirects and Google
+// News links) is done.
+func WithUnwrappers(u *Unwrappers) Option {
+	return func(s *Scraper) { s.unwrap = u }
 }
 
-// New creates a Scraper with the given fetcher, extractor, and concurrency limit.
-func New(fetcher Fetcher, extractor Extractor, maxWorkers int) *Scraper {
-	return &Scraper{
-		fetcher:    fetcher,
-		extractor:  extractor,
-		maxWorkers: maxWorkers,
+// WithNormalizer sets how URLs are normalized before deduplication and
+// fetching. Without it DefaultTrackingParams are stripped.
+func WithNormalizer(n *Normalizer) Option {
+	return func(s *Scraper) { s.normalize = n }
+}
+
+// WithPolicies skips URLs on domains that p excludes. The same set should
+// be given to the HTTPFetcher via WithFetchPolicies.
+func WithPolicies(p *Policies) Option {
+	return func(s *Scraper) { s.policies = p }
+}
+
+// New creates a Scraper with the given fetcher, extractor, and shared worker pool.
+func New(fetcher Fetcher, extractor Extractor, pool *Pool, opts ...Option) *Scraper {
+	s := &Scraper{
+		fetcher:   fetcher,
+		extractor: extractor,
+		pool:      pool,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.unwrap == nil {
+		s.unwrap = NewUnwrappers(nil)
 	}
+	if s.normalize == nil {
+		s.normalize = NewNormalizer(DefaultTrackingParams)
+	}
+	return s
 }
 
-// Scrape processes urls concurrently and returns one ArticleResult per URL.
-// Errors are captured per-URL and never cause the whole operation to fail.
-func (s *Scraper) Scrape(ctx context.Context, urls []string) []internal.ArticleResult {
-	results := make([]internal.ArticleResult, len(urls))
-	sem := make(chan struct{}, s.maxWorkers)
-	var wg sync.WaitGroup
+// Scrape processes req.URLs on the shared pool and returns one ArticleResult per URL,
+// in input order. URLs that normalize to the same address are scraped once and
+// share a result. Errors are captured per-URL and never cause the whole operation
+// to fail; the only errors returned are ErrQueueFull and ErrBatchTooLarge when
+// the pool cannot take the batch. URLs not started before ctx is done fail with
+// its error.
+func (s *Scraper) Scrape(ctx context.Context, req internal.ScrapeRequest) ([]internal.ArticleResult, error) {
+	format := req.Format
+	if format == "" {
+		format = internal.FormatTextFlat
+	}
 
-	for i, u := range urls {
-		wg.Add(1)
-		go func(idx int, url string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+	// slot[i] is the index in unique of req.URLs[i].
+	var unique, snippets []string
+	seen := make(map[string]int)
+	slot := make([]int, len(req.URLs))
+	for i, u := range req.URLs {
+		norm := s.normalize.Normalize(u)
+		// URLs known to serve the same article are scraped once too.
+		id := s.aliases.resolve(norm)
+		j, ok := seen[id]
+		if !ok {
+			j = len(unique)
+			seen[id] = j
+			unique = append(unique, norm)
+			snippets = append(snippets, "")
+		}
+		if snippets[j] == "" {
+			snippets[j] = req.Snippets[u]
+		}
+		slot[i] = j
+	}
 
-			results[idx] = s.scrapeOne(ctx, url)
-		}(i, u)
+	scraped := make([]internal.ArticleResult, len(unique))
+	started := make([]bool, len(unique))
+	tasks := make([]func(), len(unique))
+	for i, u := range unique {
+		tasks[i] = func() {
+			started[i] = true
+			scraped[i] = s.scrapeCached(ctx, u, snippets[i], format, req.NoCache)
+		}
 	}
 
-	wg.Wait()
-	return results
+	if err := s.pool.Run(ctx, tasks); err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+	for i, u := range unique {
+		if !started[i] {
+			scraped[i] = withError(internal.ArticleResult{URL: u, Format: format}, ctx.Err())
+		}
+	}
+	results := make([]internal.ArticleResult, len(req.URLs))
+	for i, u := range req.URLs {
+		// A duplicate reports the unwrapped address scraped in its place;
+		// only original_url tells it apart.
+		r := scraped[slot[i]]
+		r.OriginalURL = u
+		results[i] = escapeResult(r, req.Escape)
+	}
+	return results, nil
 }
 
-func (s *Scraper) scrapeOne(ctx context.Context, url string) internal.ArticleResult {
-	clean := unwrapGoogleURL(url)
+// scrapeCached serves rawURL from the cache when possible and otherwise
+// scrapes it, coalescing with any in-flight scrape of the same article.
+// Articles are identified by their trusted canonical URL once a scrape has
+// seen it, and by the URL itself until then. noCache skips the lookup but
+// still refreshes the cache with a successful result.
+func (s *Scraper) scrapeCached(ctx context.Context, rawURL, snippet string, format internal.Format, noCache bool) internal.ArticleResult {
+	// The unwrapped target carries its own tracking parameters, so normalize again.
+	clean := s.normalize.Normalize(s.unwrap.Unwrap(ctx, rawURL))
+	if u, err := url.Parse(clean); err == nil {
+		if reason := s.policies.skipReason(u.Hostname()); reason != "" {
+			r := withError(internal.ArticleResult{URL: clean, Format: format}, &Error{
+				Code: internal.ErrSkipped,
+				Err:  fmt.Errorf("skipped %s: %s", clean, reason),
+			})
+			r.SkipReason = reason
+			return r
+		}
+	}
+	// Each format is a different rendering of the article, so it is part of the identity.
+	key := string(format) + " " + s.aliases.resolve(clean)
+	if s.cache != nil && !noCache {
+		if r, ok := s.cache.Get(key); ok {
+			r.URL = clean
+			r.Cached = true
+			r.Attempts = 0
+			r.LimiterWaitMS = 0
+			return r
+		}
+	}
 
-	html, err := s.fetcher.Fetch(ctx, url)
+	r, err := s.flights.do(ctx, key, func(ctx context.Context) internal.ArticleResult {
+		r := s.scrapeOne(ctx, clean, snippet, format)
+		id := clean
+		if canonical := trustedCanonical(r); canonical != "" && r.Error == "" {
+			s.aliases.add(clean, canonical)
+			id = canonical
+		}
+		if s.cache != nil && r.Error == "" {
+			s.cache.Set(string(format)+" "+id, r)
+		}
+		return r
+	})
 	if err != nil {
-		return internal.ArticleResult{URL: clean, Error: err.Error()}
+		return withError(internal.ArticleResult{URL: clean, Format: format}, err)
 	}
+	r.URL = clean
+	return r
+}
 
-	result, err := s.extractor.Extract(clean, html)
+// scrapeOne hands clean, an already unwrapped URL, to its platform handler
+// or fetches and extracts it, falling back to the page's alternates when
+// the result is poor and to archived snapshots when the article is blocked
+// or paywalled. The following pages of a paginated article are merged in.
+func (s *Scraper) scrapeOne(ctx context.Context, clean, snippet string, format internal.Format) internal.ArticleResult {
+	if result, ok := s.handlers.handle(ctx, clean, format); ok {
+		if result.Error == "" {
+			result.Pages = 1
+		}
+		return result
+	}
+	// With pagination on, the page is extracted as HTML so that its blocks
+	// can be merged with those of the following pages; renderAs converts it.
+	pageFormat := format
+	if s.maxPages > 1 {
+		pageFormat = internal.FormatHTML
+	}
+	result, body := s.fetchExtract(ctx, clean, snippet, pageFormat)
+	if body != nil && result.CanonicalURL == "" && result.FinalURL != "" {
+		result.CanonicalURL = s.normalize.Normalize(result.FinalURL)
+	}
+	if s.needsAlternate(result) {
+		result = s.tryAlternates(ctx, result, body, clean, snippet, format)
+	}
+	if len(s.archives) > 0 && needsArchive(result) {
+		result = s.tryArchives(ctx, result, clean, snippet, format)
+	}
+	if result.Error != "" {
+		return renderAs(result, format)
+	}
+	// Alternates and snapshots are single pages; the article's own pages may continue.
+	if s.maxPages > 1 && result.Variant == "" && result.Source == "" && htmlTypes[result.ContentType] {
+		result = s.stitchPages(ctx, result, body, format)
+	}
+	if result.Pages == 0 {
+		result.Pages = 1
+	}
+	return renderAs(result, format)
+}
+
+// fetchExtract fetches and extracts target. It also returns the decoded
+// page, nil if the fetch failed.
+func (s *Scraper) fetchExtract(ctx context.Context, target, snippet string, format internal.Format) (internal.ArticleResult, []byte) {
+	return s.fetchDocument(ctx, target, Document{Snippet: snippet, Format: format})
+}
+
+// fetchDocument is fetchExtract for a Document whose options are set in doc.
+func (s *Scraper) fetchDocument(ctx context.Context, target string, doc Document) (internal.ArticleResult, []byte) {
+	format := doc.Format
+	page, err := s.fetcher.Fetch(ctx, target)
+	if err != nil {
+		return withPage(withError(internal.ArticleResult{URL: target, Format: format}, err), page), nil
+	}
+
+	// Non-HTML documents go to their own extractors; HTML pages to the configured one.
+	mt := documentType(page.ContentType, page.Body)
+	extractor, body, charset := s.extractor, page.Body, ""
+	if h, ok := documentTypes[mt]; ok {
+		extractor = h.extractor
+		if !h.binary {
+			body, charset = toUTF8(page.Body, page.ContentType)
+		}
+	} else if htmlTypes[mt] {
+		body, charset = toUTF8(page.Body, page.ContentType)
+	} else {
+		return withPage(withError(internal.ArticleResult{URL: target, Format: format}, errUnsupportedType(mt, target)), page), nil
+	}
+	base := target
+	if page.FinalURL != "" {
+		base = page.FinalURL
+	}
+
+	// On error the extractor may still return partial metadata (title, site name).
+	// Relative links resolve against the post-redirect address.
+	doc.URL, doc.HTML = base, body
+	var result internal.ArticleResult
+	if ce, ok := extractor.(ContextExtractor); ok {
+		result, err = ce.ExtractContext(ctx, doc)
+	} else {
+		result, err = extractor.Extract(doc)
+	}
+	result.URL = target
+	result.ContentType = mt
+	result.Charset = charset
+	if result.CanonicalURL != "" {
+		result.CanonicalURL = s.normalize.Normalize(result.CanonicalURL)
+	}
 	if err != nil {
-		return internal.ArticleResult{URL: clean, Error: err.Error()}
+		result.Format = format
+		return withPage(withError(result, err), page), body
 	}
 
-	return result
+	return withPage(result, page), body
+}
+
+// withError records err and its classification in r.
+func withError(r internal.ArticleResult, err error) internal.ArticleResult {
+	e := classify(err)
+	r.Error = e.Error()
+	r.ErrorCode = e.Code
+	r.Retryable = e.Retryable
+	return r
+}
+
+// withPage copies fetch diagnostics from page into r.
+func withPage(r internal.ArticleResult, page Page) internal.ArticleResult {
+	r.LimiterWaitMS = page.LimiterWait.Milliseconds()
+	r.Attempts = page.Attempts
+	r.HTTPStatus = page.Status
+	r.FinalURL = page.FinalURL
+	r.RedirectChain = page.Redirects
+	return r
 }
@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+
+	"github.com/val/autoga/internal"
+)
+
+// fetchFunc is a Fetcher backed by a function.
+type fetchFunc func(ctx context.Context, url string) (Page, error)
+
+func (f fetchFunc) Fetch(ctx context.Context, url string) (Page, error) { return f(ctx, url) }
+
+// textExtractor returns the whole page, rendered in the requested format,
+// as its content.
+type textExtractor struct{}
+
+func (textExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	root, err := html.Parse(bytes.NewReader(doc.HTML))
+	if err != nil {
+		return internal.ArticleResult{}, err
+	}
+	body := dom.QuerySelector(root, "body")
+	return internal.ArticleResult{URL: doc.URL, Format: doc.Format, Content: render(body, doc.Format)}, nil
+}
+
+func htmlPage(body string) Page {
+	return Page{Body: []byte(body), ContentType: "text/html; charset=utf-8", Attempts: 1, Status: 200}
+}
+
+// newTestScraper returns a Scraper reading pages from fetch with textExtractor.
+func newTestScraper(t *testing.T, fetch fetchFunc, opts ...Option) *Scraper {
+	t.Helper()
+	return New(fetch, textExtractor{}, NewPool(4, 100), opts...)
+}
+
+func TestScrapeDeduplicatesInOrder(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	s := newTestScraper(t, func(ctx context.Context, u string) (Page, error) {
+		mu.Lock()
+		fetched[u]++
+		mu.Unlock()
+		return htmlPage("<p>page " + u[len(u)-1:] + "</p>"), nil
+	})
+
+	urls := []string{
+		"https://Example.com:443/a?utm_source=alerts",
+		"https://example.com/b",
+		"https://www.google.com/url?q=https://example.com/c&sa=U",
+		"https://example.com/a#top",
+		"https://example.com/b?fbclid=x",
+	}
+	rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: urls})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	want := []struct{ url, content string }{
+		{"https://example.com/a", "page a"},
+		{"https://example.com/b", "page b"},
+		{"https://example.com/c", "page c"},
+		{"https://example.com/a", "page a"},
+		{"https://example.com/b", "page b"},
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("got %d results, want %d", len(rs), len(want))
+	}
+	for i, w := range want {
+		if rs[i].URL != w.url || rs[i].OriginalURL != urls[i] || rs[i].Content != w.content {
+			t.Errorf("result %d: got url %q, original %q, content %q, want %q, %q, %q",
+				i, rs[i].URL, rs[i].OriginalURL, rs[i].Content, w.url, urls[i], w.content)
+		}
+	}
+	for u, n := range fetched {
+		if n != 1 {
+			t.Errorf("%s fetched %d times", u, n)
+		}
+	}
+	if len(fetched) != 3 {
+		t.Errorf("fetched %v, want three pages", fetched)
+	}
+}
+
+func TestScrapeAliasReportsUnwrappedURL(t *testing.T) {
+	s := newTestScraper(t, func(ctx context.Context, u string) (Page, error) {
+		return htmlPage("<p>article</p>"), nil
+	})
+	// Both wrap the same article; the second is deduplicated against the first.
+	urls := []string{
+		"https://example.com/c?utm_medium=email",
+		"https://www.google.com/url?q=https://example.com/c",
+	}
+	// Make the wrapper resolve to the same identity as the first URL.
+	s.aliases.add(s.normalize.Normalize(urls[1]), "https://example.com/c")
+	rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: urls})
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	for i, r := range rs {
+		if r.URL != "https://example.com/c" || r.OriginalURL != urls[i] {
+			t.Errorf("result %d: got url %q, original %q", i, r.URL, r.OriginalURL)
+		}
+	}
+}
+
+// canonicalExtractor is textExtractor also reporting the page's canonical URL.
+type canonicalExtractor struct{}
+
+func (canonicalExtractor) Extract(doc Document) (internal.ArticleResult, error) {
+	r, err := textExtractor{}.Extract(doc)
+	if err != nil {
+		return r, err
+	}
+	root, err := html.Parse(bytes.NewReader(doc.HTML))
+	if err != nil {
+		return r, err
+	}
+	base, err := url.Parse(doc.URL)
+	if err != nil {
+		return r, err
+	}
+	r.CanonicalURL = extractMetadata(root, base).Canonical
+	return r, nil
+}
+
+func TestScrapeCachesByTrustedCanonical(t *testing.T) {
+	cache, err := NewResultCache(time.Hour, 10, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonicals := map[string]string{
+		"https://example.com/story?from=rss": "https://www.example.com/story",
+		"https://example.com/gone":           "https://example.com/",
+		"https://mirror.example/story":       "https://example.com/copied",
+	}
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	s := New(fetchFunc(func(ctx context.Context, u string) (Page, error) {
+		mu.Lock()
+		fetched[u]++
+		mu.Unlock()
+		return htmlPage(`<link rel="canonical" href="` + canonicals[u] + `"><p>` + u + `</p>`), nil
+	}), canonicalExtractor{}, NewPool(1, 10), WithCache(cache))
+
+	scrape := func(u string) {
+		t.Helper()
+		rs, err := s.Scrape(context.Background(), internal.ScrapeRequest{URLs: []string{u}})
+		if err != nil || rs[0].Error != "" {
+			t.Fatalf("Scrape %s: %v %+v", u, err, rs)
+		}
+	}
+	for _, u := range []string{"https://example.com/story?from=rss", "https://example.com/gone", "https://mirror.example/story"} {
+		scrape(u)
+	}
+	// The first page is cached under its canonical URL, which a later link
+	// to the canonical itself and a repeat of the first link both hit.
+	scrape("https://www.example.com/story")
+	scrape("https://example.com/story?from=rss")
+	// Untrusted canonicals identify nothing, so their targets are fetched.
+	scrape("https://example.com/")
+	scrape("https://example.com/copied")
+
+	want := map[string]int{
+		"https://example.com/story?from=rss": 1,
+		"https://example.com/gone":           1,
+		"https://mirror.example/story":       1,
+		"https://example.com/":               1,
+		"https://example.com/copied":         1,
+	}
+	if !maps.Equal(fetched, want) {
+		t.Errorf("fetched %v, want %v", fetched, want)
+	}
+}
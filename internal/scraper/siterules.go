@@ -0,0 +1,259 @@
+package scraper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+)
+
+// rulesReloadInterval is how often the rules file is checked for changes.
+const rulesReloadInterval = 10 * time.Second
+
+// SiteRules holds declarative extraction rules for sources readability gets
+// wrong. Rules are read from a JSON file, which is re-read whenever its
+// modification time changes, so edits apply without a restart.
+type SiteRules struct {
+	path string
+
+	mu      sync.Mutex
+	rules   []*siteRule
+	modTime time.Time
+	checked time.Time
+}
+
+// siteRule applies to URLs on Domain (and its subdomains) that match
+// URLPattern; an empty condition matches everything, but a rule must set
+// at least one. Selectors are CSS.
+type siteRule struct {
+	Name       string   `json:"name"`
+	Domain     string   `json:"domain"`
+	URLPattern string   `json:"url_pattern"`
+	Body       string   `json:"body"`
+	Title      string   `json:"title"`
+	Date       string   `json:"date"`
+	Author     string   `json:"author"`
+	Remove     []string `json:"remove"`
+
+	urlRe                   *regexp.Regexp
+	body, title, date, auth cascadia.Matcher
+	remove                  []cascadia.Matcher
+}
+
+// ruleResult is what a rule extracted; empty fields fall back to readability.
+type ruleResult struct {
+	name   string
+	body   *html.Node // copies of the matched body nodes, nil if none matched
+	title  string
+	date   string
+	author string
+}
+
+// NewSiteRules loads rules from path, a JSON array of objects with the
+// fields name, domain, url_pattern, body, title, date, author and remove.
+func NewSiteRules(path string) (*SiteRules, error) {
+	s := &SiteRules{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("site rules: %w", err)
+	}
+	rules, err := loadSiteRules(path)
+	if err != nil {
+		return nil, err
+	}
+	s.rules, s.modTime, s.checked = rules, info.ModTime(), time.Now()
+	return s, nil
+}
+
+// match returns the first rule for u, reloading the file if it changed.
+// A nil *SiteRules matches nothing.
+func (s *SiteRules) match(u *url.URL) *siteRule {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+
+	for _, r := range s.rules {
+		if r.Domain != "" && !matchesDomain(u.Hostname(), r.Domain) {
+			continue
+		}
+		if r.urlRe != nil && !r.urlRe.MatchString(u.String()) {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// reload re-reads the file at most every rulesReloadInterval. A file that
+// fails to load is logged and the previous rules stay in effect. Must hold s.mu.
+func (s *SiteRules) reload() {
+	if time.Since(s.checked) < rulesReloadInterval {
+		return
+	}
+	s.checked = time.Now()
+	info, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("site rules: %v; keeping previous rules", err)
+		return
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return
+	}
+	rules, err := loadSiteRules(s.path)
+	if err != nil {
+		log.Printf("%v; keeping previous rules", err)
+		return
+	}
+	s.rules, s.modTime = rules, info.ModTime()
+	log.Printf("site rules: reloaded %d rules from %s", len(rules), s.path)
+}
+
+func loadSiteRules(path string) ([]*siteRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("site rules: %w", err)
+	}
+	var rules []*siteRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("site rules %s: %w", path, err)
+	}
+	for i, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("site rules %s: rule %d: %w", path, i, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r *siteRule) compile() error {
+	if r.Domain == "" && r.URLPattern == "" {
+		return errors.New("domain or url_pattern is required")
+	}
+	r.Domain = hostKey(r.Domain)
+	if r.Name == "" {
+		r.Name = firstNonEmpty(r.Domain, r.URLPattern)
+	}
+	if r.URLPattern != "" {
+		re, err := regexp.Compile(r.URLPattern)
+		if err != nil {
+			return fmt.Errorf("url_pattern: %w", err)
+		}
+		r.urlRe = re
+	}
+
+	var err error
+	compile := func(field, sel string) cascadia.Matcher {
+		if err != nil {
+			return nil
+		}
+		if strings.TrimSpace(sel) == "" {
+			if sel != "" {
+				err = fmt.Errorf("%s selector is blank", field)
+			}
+			return nil
+		}
+		var m cascadia.SelectorGroup
+		if m, err = cascadia.ParseGroup(sel); err != nil {
+			err = fmt.Errorf("%s selector %q: %w", field, sel, err)
+			return nil
+		}
+		return m
+	}
+	r.body = compile("body", r.Body)
+	r.title = compile("title", r.Title)
+	r.date = compile("date", r.Date)
+	r.auth = compile("author", r.Author)
+	for i, sel := range r.Remove {
+		// An unset field is fine, but an empty remove entry would leave a nil matcher.
+		if strings.TrimSpace(sel) == "" {
+			return fmt.Errorf("remove selector %d is empty", i)
+		}
+		r.remove = append(r.remove, compile("remove", sel))
+	}
+	return err
+}
+
+// apply removes the rule's unwanted nodes from doc, which readability will
+// then also see, and extracts the fields the rule has selectors for.
+func (r *siteRule) apply(doc *html.Node, base *url.URL) ruleResult {
+	for _, m := range r.remove {
+		for _, n := range cascadia.QueryAll(doc, m) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+
+	res := ruleResult{name: r.Name}
+	if r.title != nil {
+		if n := cascadia.Query(doc, r.title); n != nil {
+			res.title = tidyInline(textContent(n))
+		}
+	}
+	if r.auth != nil {
+		if n := cascadia.Query(doc, r.auth); n != nil {
+			res.author = tidyInline(textContent(n))
+		}
+	}
+	if r.date != nil {
+		if n := cascadia.Query(doc, r.date); n != nil {
+			res.date = firstDate(attr(n, "datetime"), attr(n, "content"), textContent(n))
+		}
+	}
+	if r.body != nil {
+		if body := collectNodes(cascadia.QueryAll(doc, r.body), base); body.FirstChild != nil {
+			res.body = body
+		}
+	}
+	return res
+}
+
+// collectNodes copies nodes into one container, skipping nodes nested in
+// another match and resolving relative links against base.
+func collectNodes(nodes []*html.Node, base *url.URL) *html.Node {
+	matched := make(map[*html.Node]bool, len(nodes))
+	for _, n := range nodes {
+		matched[n] = true
+	}
+	container := &html.Node{Type: html.ElementNode, Data: "div"}
+outer:
+	for _, n := range nodes {
+		for p := n.Parent; p != nil; p = p.Parent {
+			if matched[p] {
+				continue outer
+			}
+		}
+		c := dom.Clone(n, true)
+		resolveLinks(c, base)
+		container.AppendChild(c)
+	}
+	return container
+}
+
+func resolveLinks(n *html.Node, base *url.URL) {
+	if n.Type == html.ElementNode {
+		for i, a := range n.Attr {
+			if a.Key == "href" || a.Key == "src" {
+				if abs := resolveURL(base, strings.TrimSpace(a.Val)); abs != "" {
+					n.Attr[i].Val = abs
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		resolveLinks(c, base)
+	}
+}
@@ -0,0 +1,70 @@
+package scraper
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func parseTestHTML(t *testing.T, s string) *html.Node {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func writeRules(t *testing.T, path, rules string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSiteRulesRejectBlankSelectors(t *testing.T) {
+	tests := []string{
+		`[{"domain": "example.com", "remove": [""]}]`,
+		`[{"domain": "example.com", "remove": [".ad", "  "]}]`,
+		`[{"domain": "example.com", "body": " \t"}]`,
+	}
+	path := filepath.Join(t.TempDir(), "rules.json")
+	for _, rules := range tests {
+		writeRules(t, path, rules, time.Now())
+		if _, err := NewSiteRules(path); err == nil {
+			t.Errorf("%s: loaded, want an error", rules)
+		}
+	}
+}
+
+func TestSiteRulesReloadKeepsPreviousOnBadEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	start := time.Now().Add(-time.Hour)
+	writeRules(t, path, `[{"name": "good", "domain": "example.com", "remove": [".ad"]}]`, start)
+	s, err := NewSiteRules(path)
+	if err != nil {
+		t.Fatalf("NewSiteRules: %v", err)
+	}
+
+	writeRules(t, path, `[{"name": "bad", "domain": "example.com", "remove": [""]}]`, start.Add(time.Minute))
+	s.checked = time.Time{} // skip the reload interval
+	u, _ := url.Parse("https://www.example.com/news/1")
+	r := s.match(u)
+	if r == nil || r.Name != "good" {
+		t.Fatalf("got rule %+v, want the previous rule", r)
+	}
+
+	doc := parseTestHTML(t, `<div class="ad">buy</div><p>story</p>`)
+	r.apply(doc, u)
+	if got := textContent(doc); strings.Contains(got, "buy") || !strings.Contains(got, "story") {
+		t.Errorf("after apply the page reads %q", got)
+	}
+}
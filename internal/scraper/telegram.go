@@ -0,0 +1,169 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-shiori/dom"
+	"golang.org/x/net/html"
+
+	"github.com/val/autoga/internal"
+)
+
+// DefaultTelegramBase is the address Telegram's channel previews are
+// requested from.
+const DefaultTelegramBase = "https://t.me"
+
+const extractorTelegram = "telegram"
+
+// telegramChannelRe matches a public channel's username.
+var telegramChannelRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// telegramPostRe matches a message id.
+var telegramPostRe = regexp.MustCompile(`^[0-9]{1,10}$`)
+
+// telegramReserved are t.me paths that look like channel names but are not.
+var telegramReserved = map[string]bool{
+	"joinchat": true, "addstickers": true, "addemoji": true, "addtheme": true,
+	"share": true, "proxy": true, "socks": true, "setlanguage": true, "iv": true,
+}
+
+// backgroundURLRe finds the address in a CSS background-image declaration.
+var backgroundURLRe = regexp.MustCompile(`background-image:\s*url\(['"]?([^'")]+)['"]?\)`)
+
+// TelegramHandler reads posts of public channels from the web preview at
+// t.me/s/<channel>, which renders a channel's messages as plain HTML.
+type TelegramHandler struct {
+	fetcher PlatformFetcher
+	base    string
+}
+
+// NewTelegramHandler creates a TelegramHandler requesting base through f.
+// An empty base means DefaultTelegramBase.
+func NewTelegramHandler(f PlatformFetcher, base string) *TelegramHandler {
+	return &TelegramHandler{fetcher: f, base: endpointBase(base, DefaultTelegramBase)}
+}
+
+func (*TelegramHandler) Hosts() []string {
+	return []string{"t.me", "telegram.me"}
+}
+
+// Handle scrapes t.me/<channel>/<id> and t.me/s/<channel>/<id> links. The
+// first line of the message becomes the title.
+func (t *TelegramHandler) Handle(ctx context.Context, u *url.URL, format internal.Format) (internal.ArticleResult, bool, error) {
+	channel, id := telegramPost(u)
+	if channel == "" {
+		return internal.ArticleResult{}, false, nil
+	}
+	post := channel + "/" + id
+	r := internal.ArticleResult{Format: format, SiteName: "Telegram", CanonicalURL: DefaultTelegramBase + "/" + post}
+	r.FinalURL = r.CanonicalURL
+
+	target := t.base + "/s/" + post
+	body, err := fetchPlatform(ctx, t.fetcher, target, http.Header{"Accept": {"text/html"}}, &r)
+	if err != nil {
+		return r, true, err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return r, true, errUnexpectedAnswer(target, err)
+	}
+
+	// The preview lists the messages around the requested one.
+	var msg *html.Node
+	for _, n := range dom.QuerySelectorAll(doc, ".tgme_widget_message[data-post]") {
+		if strings.EqualFold(dom.GetAttribute(n, "data-post"), post) {
+			msg = n
+			break
+		}
+	}
+	if msg == nil {
+		return r, true, &Error{
+			Code: internal.ErrExtractionEmpty,
+			Err:  fmt.Errorf("post %s not in the preview of %s; the channel may be private or hide its preview", post, channel),
+		}
+	}
+
+	owner := tidyInline(textOf(dom.QuerySelector(msg, ".tgme_widget_message_owner_name")))
+	r.SiteName = firstNonEmpty(owner, r.SiteName)
+	r.Byline = firstNonEmpty(tidyInline(textOf(dom.QuerySelector(msg, ".tgme_widget_message_from_author"))), owner)
+	if tm := dom.QuerySelector(msg, ".tgme_widget_message_date time"); tm != nil {
+		r.PublishedAt = firstDate(dom.GetAttribute(tm, "datetime"))
+	}
+	for _, n := range dom.QuerySelectorAll(msg, ".tgme_widget_message_photo_wrap, .tgme_widget_message_video_thumb") {
+		if m := backgroundURLRe.FindStringSubmatch(dom.GetAttribute(n, "style")); m != nil {
+			r.ImageURL = m[1]
+			break
+		}
+	}
+
+	var paras []string
+	for _, n := range dom.QuerySelectorAll(msg, ".tgme_widget_message_text") {
+		// A reply quotes the message it answers in the same markup.
+		if !insideClass(n, "tgme_widget_message_reply") {
+			paras = splitLines(lineText(n))
+			break
+		}
+	}
+	r.Title = firstNonEmpty(firstLine(paras), owner)
+
+	r, err = finishDocument(r, paragraphCandidate(extractorTelegram, paras, r.Format))
+	return r, true, err
+}
+
+// telegramPost returns the channel and message id u links to, empty if it
+// is not a link to a public channel's post.
+func telegramPost(u *url.URL) (channel, id string) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) == 3 && parts[0] == "s" {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || telegramReserved[strings.ToLower(parts[0])] ||
+		!telegramChannelRe.MatchString(parts[0]) || !telegramPostRe.MatchString(parts[1]) {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// textOf returns the text of n, "" for nil.
+func textOf(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	return textContent(n)
+}
+
+// insideClass reports whether an ancestor of n has class.
+func insideClass(n *html.Node, class string) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && slices.Contains(strings.Fields(attr(p, "class")), class) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineText returns the text of n with <br> turned into line breaks.
+func lineText(n *html.Node) string {
+	var b strings.Builder
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.Data == "br":
+			b.WriteByte('\n')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(n)
+	return b.String()
+}
@@ -2,16 +2,50 @@ package scraper
 
 import (
 	"context"
+	"time"
 
 	"github.com/val/autoga/internal"
 )
 
+// Page is the outcome of a single Fetch. Diagnostic fields are populated
+// even when Fetch returns an error.
+type Page struct {
+	Body        []byte
+	ContentType string        // Content-Type header of the response, passed on to pick the extractor
+	LimiterWait time.Duration // time spent waiting on the per-host limiter
+	Attempts    int           // HTTP attempts made, including retries
+	Status      int           // status of the last response, zero if none arrived
+	FinalURL    string        // URL of the last response, after redirects
+	Redirects   []string      // every URL requested, oldest first; nil without redirects
+}
+
 // Fetcher retrieves raw HTML content for a given URL.
 type Fetcher interface {
-	Fetch(ctx context.Context, url string) ([]byte, error)
+	Fetch(ctx context.Context, url string) (Page, error)
+}
+
+// Document is a fetched page handed to an Extractor.
+type Document struct {
+	URL    string
+	HTML   []byte // UTF-8 encoded; raw bytes for binary documents such as PDF
+	Format internal.Format
+	// Snippet is the feed's summary of the article, if the caller has one.
+	Snippet string
+	// Snapshot marks an archived copy of the page. Its paywall metadata and
+	// markup are copied from the original, so only its text can show a wall.
+	Snapshot bool
 }
 
-// Extractor parses raw HTML and returns a populated ArticleResult.
+// Extractor parses a Document and returns a populated ArticleResult. The
+// Scraper hands HTML pages to its configured Extractor and other document
+// types to built-in ones.
 type Extractor interface {
-	Extract(url string, html []byte) (internal.ArticleResult, error)
+	Extract(doc Document) (internal.ArticleResult, error)
+}
+
+// ContextExtractor is an Extractor whose work can be cut short. The Scraper
+// calls ExtractContext with the request's context instead of Extract.
+type ContextExtractor interface {
+	Extractor
+	ExtractContext(ctx context.Context, doc Document) (internal.ArticleResult, error)
 }
@@ -0,0 +1,286 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/val/autoga/internal/useragent"
+)
+
+// maxUnwrapHops bounds how many wrappers may be nested inside each other.
+const maxUnwrapHops = 5
+
+// maxResolved bounds the memo of network-resolved wrapper URLs.
+const maxResolved = 1024
+
+// Unwrapper resolves a wrapper URL (a redirector, tracker or shortener) to
+// the URL it points to.
+type Unwrapper interface {
+	// Unwrap returns the destination of u, or false if u is not a URL this
+	// unwrapper handles or it could not be resolved.
+	Unwrap(ctx context.Context, u *url.URL) (string, bool)
+}
+
+// Unwrappers is a registry of Unwrapper implementations consulted in order.
+// One registry is shared by the Scraper and the HTTPFetcher.
+type Unwrappers struct {
+	list []Unwrapper
+}
+
+// defaultShorteners are hosts whose links are resolved by reading the
+// redirect they answer with.
+var defaultShorteners = []string{
+	"t.co", "bit.ly", "lnkd.in", "ow.ly", "buff.ly", "tinyurl.com", "dlvr.it",
+	"ift.tt", "trib.al", "fb.me", "feedproxy.google.com", "feeds.feedburner.com",
+}
+
+// NewUnwrappers builds a registry with the built-in handlers: Google
+// redirects on any Google domain, Google News article links, and common
+// shorteners and FeedBurner resolved over client. extra handlers are
+// consulted after the offline built-ins and before network resolution.
+// A nil client leaves out the network-resolved built-ins.
+func NewUnwrappers(client *http.Client, extra ...Unwrapper) *Unwrappers {
+	list := []Unwrapper{googleRedirect{}, googleNews{}}
+	list = append(list, extra...)
+	if client != nil {
+		list = append(list, NewRedirectUnwrapper(client, defaultShorteners...))
+	}
+	return &Unwrappers{list: list}
+}
+
+// Unwrap follows wrappers starting at raw until none applies and returns
+// the innermost URL. Unparseable or unresolvable URLs are returned as-is;
+// the fetcher's own redirect handling then takes over.
+func (r *Unwrappers) Unwrap(ctx context.Context, raw string) string {
+	cur := raw
+	for range maxUnwrapHops {
+		u, err := url.Parse(cur)
+		if err != nil {
+			return cur
+		}
+		next := ""
+		for _, uw := range r.list {
+			if target, ok := uw.Unwrap(ctx, u); ok && target != cur {
+				next = target
+				break
+			}
+		}
+		if next == "" {
+			return cur
+		}
+		cur = next
+	}
+	return cur
+}
+
+// QueryUnwrapper takes the destination from a query parameter of matching URLs.
+type QueryUnwrapper struct {
+	domain     string
+	pathPrefix string
+	params     []string
+}
+
+// NewQueryUnwrapper handles URLs on domain (and its subdomains) whose path
+// starts with pathPrefix, taking the destination from the first non-empty
+// parameter in params.
+func NewQueryUnwrapper(domain, pathPrefix string, params ...string) *QueryUnwrapper {
+	return &QueryUnwrapper{domain: hostKey(domain), pathPrefix: pathPrefix, params: params}
+}
+
+func (q *QueryUnwrapper) Unwrap(_ context.Context, u *url.URL) (string, bool) {
+	if !matchesDomain(u.Hostname(), q.domain) || !strings.HasPrefix(u.Path, q.pathPrefix) {
+		return "", false
+	}
+	return queryTarget(u, q.params...)
+}
+
+// googleRedirect handles https://www.google.<tld>/url?url=<target> and the
+// q= variant used by search results.
+type googleRedirect struct{}
+
+var googleHostRe = regexp.MustCompile(`^(www\.)?google\.[a-z]{2,3}(\.[a-z]{2})?$`)
+
+func (googleRedirect) Unwrap(_ context.Context, u *url.URL) (string, bool) {
+	if u.Path != "/url" || !googleHostRe.MatchString(strings.ToLower(u.Hostname())) {
+		return "", false
+	}
+	return queryTarget(u, "url", "q")
+}
+
+// googleNews decodes news.google.com/rss/articles/<id> links offline. The
+// id is base64 of a small protobuf whose first string field holding an
+// http(s) URL is the article. Newer ids carry only an opaque token and are
+// left for the fetcher to follow.
+type googleNews struct{}
+
+func (googleNews) Unwrap(_ context.Context, u *url.URL) (string, bool) {
+	if !strings.EqualFold(u.Hostname(), "news.google.com") {
+		return "", false
+	}
+	_, id, found := strings.Cut(u.Path, "/articles/")
+	if !found || id == "" {
+		return "", false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(id, "="))
+	if err != nil {
+		return "", false
+	}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return "", false
+		}
+		data = data[n:]
+		switch tag & 7 { // wire type
+		case 0: // varint
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return "", false
+			}
+			data = data[n:]
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return "", false
+			}
+			data = data[8:]
+		case 2: // length-delimited
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return "", false
+			}
+			field := data[n : n+int(size)]
+			if bytes.HasPrefix(field, []byte("http")) {
+				return httpURL(string(field))
+			}
+			data = data[n+int(size):]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return "", false
+			}
+			data = data[4:]
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// RedirectUnwrapper resolves shortener links by requesting them without
+// following redirects and reading the Location header.
+type RedirectUnwrapper struct {
+	domains []string
+	client  *http.Client
+
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+// NewRedirectUnwrapper handles links on domains (and their subdomains)
+// using client for the lookups. Redirects are never followed by the lookup
+// itself; each hop is a separate Unwrap.
+func NewRedirectUnwrapper(client *http.Client, domains ...string) *RedirectUnwrapper {
+	noFollow := *client
+	noFollow.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	keys := make([]string, len(domains))
+	for i, d := range domains {
+		keys[i] = hostKey(d)
+	}
+	return &RedirectUnwrapper{
+		domains:  keys,
+		client:   &noFollow,
+		resolved: make(map[string]string),
+	}
+}
+
+func (r *RedirectUnwrapper) Unwrap(ctx context.Context, u *url.URL) (string, bool) {
+	host := u.Hostname()
+	handled := false
+	for _, d := range r.domains {
+		if matchesDomain(host, d) {
+			handled = true
+			break
+		}
+	}
+	if !handled {
+		return "", false
+	}
+
+	key := u.String()
+	r.mu.Lock()
+	target, ok := r.resolved[key]
+	r.mu.Unlock()
+	if ok {
+		return target, true
+	}
+
+	// Some shorteners reject HEAD, so fall back to GET.
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		if target, ok = r.location(ctx, method, u); ok {
+			r.mu.Lock()
+			if len(r.resolved) >= maxResolved {
+				clear(r.resolved)
+			}
+			r.resolved[key] = target
+			r.mu.Unlock()
+			return target, true
+		}
+	}
+	return "", false
+}
+
+func (r *RedirectUnwrapper) location(ctx context.Context, method string, u *url.URL) (string, bool) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return "", false
+	}
+	req.Header.Set("User-Agent", useragent.Next())
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", false
+	}
+	drain(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", false
+	}
+	loc, err := resp.Location()
+	if err != nil {
+		return "", false
+	}
+	return httpURL(loc.String())
+}
+
+// queryTarget returns the first of params present in u's query as an http(s) URL.
+func queryTarget(u *url.URL, params ...string) (string, bool) {
+	q := u.Query()
+	for _, p := range params {
+		if target := q.Get(p); target != "" {
+			return httpURL(target)
+		}
+	}
+	return "", false
+}
+
+// httpURL validates that s is an absolute http or https URL.
+func httpURL(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	return s, true
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains.
+// domain must already be in hostKey form.
+func matchesDomain(host, domain string) bool {
+	host = hostKey(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
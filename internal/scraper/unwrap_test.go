@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// googleNewsID encodes target the way news.google.com article ids do:
+// field 1 as a varint, field 4 holding the URL, then trailing fields.
+func googleNewsID(target string) string {
+	data := []byte{0x08, 0x13, 0x22, byte(len(target))}
+	data = append(data, target...)
+	data = append(data, 0xd2, 0x01, 0x00, 0x2a, 0x05, 'h', 't', 't', 'p', 's')
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestUnwrapOffline(t *testing.T) {
+	u := NewUnwrappers(nil, NewQueryUnwrapper("news.example.com", "/out", "to", "dest"))
+	tests := []struct {
+		name, in, want string
+	}{
+		{"google url param", "https://www.google.com/url?rct=j&url=https://example.com/a&ct=ga", "https://example.com/a"},
+		{"google q param", "https://www.google.co.uk/url?q=https://example.com/b&sa=U", "https://example.com/b"},
+		{"google ccTLD without www", "https://google.de/url?url=https://example.com/c", "https://example.com/c"},
+		{"google other path", "https://www.google.com/search?q=https://example.com/a", "https://www.google.com/search?q=https://example.com/a"},
+		{"google non-http target", "https://www.google.com/url?q=javascript:alert(1)", "https://www.google.com/url?q=javascript:alert(1)"},
+		{"lookalike host", "https://google.com.evil.example/url?q=https://example.com/a", "https://google.com.evil.example/url?q=https://example.com/a"},
+		{"google news", "https://news.google.com/rss/articles/" + googleNewsID("https://example.com/story?id=1") + "?oc=5", "https://example.com/story?id=1"},
+		{"google news opaque id", "https://news.google.com/rss/articles/CBMiAA?oc=5", "https://news.google.com/rss/articles/CBMiAA?oc=5"},
+		{"query unwrapper", "https://www.news.example.com/out/1?dest=https://example.com/d", "https://example.com/d"},
+		{"query unwrapper first param wins", "https://news.example.com/out?to=https://example.com/e&dest=https://example.com/f", "https://example.com/e"},
+		{"query unwrapper other path", "https://news.example.com/in?to=https://example.com/e", "https://news.example.com/in?to=https://example.com/e"},
+		{"nested", "https://www.google.com/url?url=https://news.example.com/out%3Fto%3Dhttps://example.com/g", "https://example.com/g"},
+		{"plain", "https://example.com/a", "https://example.com/a"},
+	}
+	for _, tt := range tests {
+		if got := u.Unwrap(context.Background(), tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGoogleNewsReadsFieldLength(t *testing.T) {
+	// The URL field is followed directly by another string field whose tag
+	// and length bytes (':' and ' ') are printable.
+	data := append([]byte("\x08\x13\x22\x15https://example.com/a\x3a\x20"), strings.Repeat("x", 0x20)...)
+	u := mustParse(t, "https://news.google.com/rss/articles/"+base64.RawURLEncoding.EncodeToString(data))
+	if got, ok := (googleNews{}).Unwrap(context.Background(), u); !ok || got != "https://example.com/a" {
+		t.Errorf("got %q, %v, want the URL field alone", got, ok)
+	}
+	// A length running past the end of the id is rejected.
+	bad := base64.RawURLEncoding.EncodeToString([]byte("\x08\x13\x22\x40https://example.com/a"))
+	u = mustParse(t, "https://news.google.com/rss/articles/"+bad)
+	if got, ok := (googleNews{}).Unwrap(context.Background(), u); ok {
+		t.Errorf("truncated id got %q", got)
+	}
+}
+
+func TestUnwrapShorteners(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.Host + r.URL.Path {
+		case "t.co/abc":
+			http.Redirect(w, r, "http://bit.ly/xyz", http.StatusMovedPermanently)
+		case "bit.ly/xyz":
+			if r.Method == http.MethodHead {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, r, "https://example.com/from-bitly", http.StatusFound)
+		case "feeds.feedburner.com/~r/site/~3/1":
+			http.Redirect(w, r, "https://example.com/from-feed", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	// The stub answers for every shortener host.
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+	u := NewUnwrappers(client)
+
+	tests := []struct {
+		name, in, want string
+	}{
+		{"t.co to bit.ly with GET fallback", "http://t.co/abc", "https://example.com/from-bitly"},
+		{"feedburner", "http://feeds.feedburner.com/~r/site/~3/1", "https://example.com/from-feed"},
+		{"unknown link", "http://t.co/missing", "http://t.co/missing"},
+		{"not a shortener", "http://example.com/a", "http://example.com/a"},
+	}
+	for _, tt := range tests {
+		if got := u.Unwrap(context.Background(), tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Resolved links are memoized.
+	before := requests.Load()
+	if got := u.Unwrap(context.Background(), "http://t.co/abc"); got != "https://example.com/from-bitly" {
+		t.Errorf("memoized lookup got %q", got)
+	}
+	if n := requests.Load() - before; n != 0 {
+		t.Errorf("memoized lookup made %d requests", n)
+	}
+}
@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Values of ArticleResult.Wall.
+const (
+	wallPaywall = "paywalled"
+	wallConsent = "consent_wall"
+)
+
+// paywallMarkers are class and id fragments of paywall and metering widgets.
+var paywallMarkers = []string{
+	"paywall", "regwall", "piano-offer", "tp-modal", "tp-backdrop", "tinypass",
+	"laterpay", "poool-widget", "meteredcontent", "metered-content",
+	"subscriber-only", "subscribers-only", "premium-content", "article-locked", "locked-content",
+}
+
+// consentManagers maps class, id and script src fragments to the consent
+// manager they belong to.
+var consentManagers = []struct{ marker, name string }{
+	{"onetrust", "OneTrust"},
+	{"cookielaw.org", "OneTrust"},
+	{"cybotcookiebot", "Cookiebot"},
+	{"cookiebot.com", "Cookiebot"},
+	{"qc-cmp2", "Quantcast Choice"},
+	{"sp_message_container", "Sourcepoint"},
+	{"didomi", "Didomi"},
+	{"privacy-center.org", "Didomi"},
+	{"truste", "TrustArc"},
+	{"usercentrics", "Usercentrics"},
+	{"cmpbox", "consentmanager"},
+	{"fc-consent-root", "Google Funding Choices"},
+	{"cookie-law-info", "CookieYes"},
+	{"cky-consent", "CookieYes"},
+	{"iubenda-cs", "iubenda"},
+}
+
+// consentTerms are phrases typical of cookie banners and consent dialogs.
+var consentTerms = []string{
+	"cookie", "consent", "partners", "privacy", "personalised", "personalized",
+	"legitimate interest", "vendors", "accept all", "reject all", "manage preferences",
+	"store and/or access information",
+}
+
+// subscribePrompts are phrases a paywall leaves at the end of a teaser.
+var subscribePrompts = []string{
+	"subscribe to continue", "to continue reading", "subscribe to read", "subscribers only",
+	"for subscribers", "become a subscriber", "already a subscriber", "sign in to continue",
+	"log in to continue", "unlock this article", "start your free trial",
+}
+
+// Truncation heuristics.
+const (
+	// teaserWords is the length below which a body counts as a teaser.
+	teaserWords = 120
+	// promptTail is how much of the end of the text is searched for prompts.
+	promptTail = 400
+	// consentMaxWords is the longest text still taken for a consent dialog.
+	consentMaxWords = 200
+)
+
+// wallMarkers is what the page's markup says about walls. It is gathered
+// from the page as fetched, since readability's article leaves the widgets
+// out and a site rule may remove them.
+type wallMarkers struct {
+	locked  string // why the metadata marks the article as not free
+	paywall string // marker of the first paywall element found
+	consent string // consent manager whose markup is present
+}
+
+// findWallMarkers scans doc for paywall and consent-manager markup.
+func findWallMarkers(doc *html.Node, meta metadata) wallMarkers {
+	w := wallMarkers{locked: meta.Locked}
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && (w.paywall == "" || w.consent == "") {
+			names := strings.ToLower(attr(n, "id") + " " + attr(n, "class"))
+			if n.Data == "script" {
+				names += " " + strings.ToLower(attr(n, "src"))
+			}
+			if w.paywall == "" && n.Data != "script" && n.Data != "body" && n.Data != "html" {
+				for _, m := range paywallMarkers {
+					if strings.Contains(names, m) {
+						w.paywall = m
+						break
+					}
+				}
+			}
+			if w.consent == "" {
+				for _, cm := range consentManagers {
+					if strings.Contains(names, cm.marker) {
+						w.consent = cm.name
+						break
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	return w
+}
+
+// verdict decides whether text, the chosen content of the page at base, is
+// a paywall teaser or consent dialog, and why. It returns "" for an article.
+func (w wallMarkers) verdict(text string, base *url.URL) (wall, reason string) {
+	lower := strings.ToLower(text)
+	words := len(strings.Fields(text))
+	tail := lower[max(len(lower)-promptTail, 0):]
+
+	if base != nil && isConsentHost(base.Hostname()) {
+		return wallConsent, "redirected to consent page " + base.Hostname()
+	}
+	// Banner markup alone is on most European pages, and consent terms on
+	// privacy news; only both together with a thin body make a dialog.
+	if w.consent != "" && words < consentMaxWords {
+		found := 0
+		for _, t := range consentTerms {
+			if strings.Contains(lower, t) {
+				found++
+			}
+		}
+		if found >= 3 {
+			return wallConsent, fmt.Sprintf("content is %s consent dialog text", w.consent)
+		}
+	}
+
+	prompt := ""
+	for _, p := range subscribePrompts {
+		if strings.Contains(tail, p) {
+			prompt = p
+			break
+		}
+	}
+	truncated := prompt != "" || words < teaserWords ||
+		strings.HasSuffix(strings.TrimSpace(text), "…") || strings.HasSuffix(strings.TrimSpace(text), "...")
+	// Metadata and markup also mark articles served in full to the scraper.
+	switch {
+	case w.locked != "" && truncated:
+		return wallPaywall, w.locked + " and truncated body"
+	case w.paywall != "" && truncated:
+		return wallPaywall, fmt.Sprintf("paywall markup (%s) and truncated body", w.paywall)
+	case prompt != "":
+		return wallPaywall, fmt.Sprintf("body ends with subscription prompt %q", prompt)
+	}
+	return "", ""
+}
+
+// isConsentHost reports whether host serves consent interstitials, such as
+// consent.google.com or guce.yahoo.com.
+func isConsentHost(host string) bool {
+	host = strings.ToLower(host)
+	return strings.HasPrefix(host, "consent.") || strings.HasPrefix(host, "guce.")
+}
@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindWallMarkers(t *testing.T) {
+	doc := parseTestHTML(t, `<body class="paywall-page">
+		<div id="onetrust-banner-sdk"></div>
+		<script src="https://cdn.cookielaw.org/x.js"></script>
+		<div class="article Paywall-Inline"></div>
+	</body>`)
+	w := findWallMarkers(doc, metadata{Locked: "isAccessibleForFree is false"})
+	if w.paywall != "paywall" || w.consent != "OneTrust" || w.locked != "isAccessibleForFree is false" {
+		t.Errorf("got %+v", w)
+	}
+	if w := findWallMarkers(parseTestHTML(t, `<body class="paywall"><p>text</p></body>`), metadata{}); w.paywall != "" {
+		t.Errorf("a body class alone counted as paywall markup: %+v", w)
+	}
+}
+
+func TestWallVerdict(t *testing.T) {
+	long := strings.Repeat("The council approved the budget after a long debate. ", 30)
+	teaser := "The council approved the budget after a long debate."
+	cookie := "We and our partners use cookies to store and/or access information on a device. Accept all or manage preferences. Read our privacy policy."
+	tests := []struct {
+		name    string
+		markers wallMarkers
+		text    string
+		host    string
+		want    string
+	}{
+		{"plain article", wallMarkers{}, long, "example.com", ""},
+		{"short article without markers", wallMarkers{}, teaser, "example.com", ""},
+		{"locked and truncated", wallMarkers{locked: "isAccessibleForFree is false"}, teaser, "example.com", wallPaywall},
+		{"locked but served in full", wallMarkers{locked: "isAccessibleForFree is false"}, long, "example.com", ""},
+		{"paywall markup and truncated", wallMarkers{paywall: "regwall"}, teaser + "…", "example.com", wallPaywall},
+		{"paywall markup but served in full", wallMarkers{paywall: "regwall"}, long, "example.com", ""},
+		{"subscription prompt", wallMarkers{}, long + " Subscribe to continue reading.", "example.com", wallPaywall},
+		{"consent host", wallMarkers{}, long, "consent.google.com", wallConsent},
+		{"consent banner text", wallMarkers{consent: "OneTrust"}, cookie, "example.com", wallConsent},
+		{"cookie text without banner markup", wallMarkers{}, cookie, "example.com", ""},
+		{"privacy article with banner markup", wallMarkers{consent: "OneTrust"}, long + cookie, "example.com", ""},
+	}
+	for _, tt := range tests {
+		wall, reason := tt.markers.verdict(tt.text, mustParse(t, "https://"+tt.host+"/a"))
+		if wall != tt.want || (wall != "") != (reason != "") {
+			t.Errorf("%s: got %q (%s), want %q", tt.name, wall, reason, tt.want)
+		}
+	}
+}
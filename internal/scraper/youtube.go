@@ -0,0 +1,158 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/val/autoga/internal"
+)
+
+// DefaultYouTubeBase is the address YouTube's oEmbed endpoint and watch
+// pages are requested from.
+const DefaultYouTubeBase = "https://www.youtube.com"
+
+const extractorYouTube = "youtube"
+
+// youtubeIDRe matches a video id.
+var youtubeIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// youtubePathPrefixes are path prefixes followed by a video id.
+var youtubePathPrefixes = []string{"/shorts/", "/live/", "/embed/", "/v/"}
+
+// YouTubeHandler takes a video's title, channel and thumbnail from oEmbed
+// and its description, date and tags from the player response embedded in
+// the watch page.
+type YouTubeHandler struct {
+	fetcher PlatformFetcher
+	base    string
+}
+
+// NewYouTubeHandler creates a YouTubeHandler requesting base through f.
+// An empty base means DefaultYouTubeBase.
+func NewYouTubeHandler(f PlatformFetcher, base string) *YouTubeHandler {
+	return &YouTubeHandler{fetcher: f, base: endpointBase(base, DefaultYouTubeBase)}
+}
+
+func (*YouTubeHandler) Hosts() []string {
+	return []string{"youtube.com", "youtu.be", "youtube-nocookie.com"}
+}
+
+// Handle scrapes watch, Shorts, live, embed and youtu.be links to a video.
+// Without a description the title stands in for the content.
+func (y *YouTubeHandler) Handle(ctx context.Context, u *url.URL, format internal.Format) (internal.ArticleResult, bool, error) {
+	id := youtubeID(u)
+	if id == "" {
+		return internal.ArticleResult{}, false, nil
+	}
+	watch := DefaultYouTubeBase + "/watch?v=" + id
+	r := internal.ArticleResult{Format: format, CanonicalURL: watch, FinalURL: watch}
+
+	var oembed struct {
+		Title        string `json:"title"`
+		AuthorName   string `json:"author_name"`
+		ProviderName string `json:"provider_name"`
+		ThumbnailURL string `json:"thumbnail_url"`
+	}
+	q := url.Values{"url": {watch}, "format": {"json"}}
+	if err := getJSON(ctx, y.fetcher, y.base+"/oembed?"+q.Encode(), &r, &oembed); err != nil {
+		return r, true, err
+	}
+	r.Title = oembed.Title
+	r.Byline = oembed.AuthorName
+	r.SiteName = firstNonEmpty(oembed.ProviderName, "YouTube")
+	r.ImageURL = oembed.ThumbnailURL
+
+	// The description is optional: oEmbed alone still identifies the video.
+	player := y.player(ctx, id, &r)
+	details, micro := player.VideoDetails, player.Microformat.Renderer
+	r.Title = firstNonEmpty(r.Title, details.Title)
+	r.Byline = firstNonEmpty(r.Byline, details.Author)
+	r.PublishedAt = firstDate(micro.PublishDate, micro.UploadDate)
+	r.Section = micro.Category
+	r.Keywords = details.Keywords
+	paras := splitLines(firstNonEmpty(details.ShortDescription, micro.Description.SimpleText))
+	if len(paras) == 0 {
+		paras = []string{r.Title}
+	}
+	r.Excerpt = firstLine(paras)
+
+	r, err := finishDocument(r, paragraphCandidate(extractorYouTube, paras, r.Format))
+	return r, true, err
+}
+
+// youtubePlayer is the part of ytInitialPlayerResponse that is used.
+type youtubePlayer struct {
+	VideoDetails struct {
+		Title            string   `json:"title"`
+		Author           string   `json:"author"`
+		ShortDescription string   `json:"shortDescription"`
+		Keywords         []string `json:"keywords"`
+	} `json:"videoDetails"`
+	Microformat struct {
+		Renderer struct {
+			PublishDate string `json:"publishDate"`
+			UploadDate  string `json:"uploadDate"`
+			Category    string `json:"category"`
+			Description struct {
+				SimpleText string `json:"simpleText"`
+			} `json:"description"`
+		} `json:"playerMicroformatRenderer"`
+	} `json:"microformat"`
+}
+
+// youtubePlayerRe finds the assignment of the player response in a watch page.
+var youtubePlayerRe = regexp.MustCompile(`ytInitialPlayerResponse\s*=\s*`)
+
+// player reads the player response from the watch page of video id. It
+// returns the zero value if the page cannot be fetched or parsed.
+func (y *YouTubeHandler) player(ctx context.Context, id string, r *internal.ArticleResult) youtubePlayer {
+	var p youtubePlayer
+	header := http.Header{
+		"Accept": {"text/html"},
+		// Skips the cookie consent interstitial served to European visitors.
+		"Cookie": {"CONSENT=YES+; SOCS=CAI"},
+	}
+	// A failed watch page leaves the oEmbed response's diagnostics in place.
+	var page internal.ArticleResult
+	body, err := fetchPlatform(ctx, y.fetcher, y.base+"/watch?v="+id+"&hl=en", header, &page)
+	r.Attempts += page.Attempts
+	r.LimiterWaitMS += page.LimiterWaitMS
+	if err != nil {
+		return p
+	}
+	r.HTTPStatus, r.ContentType = page.HTTPStatus, page.ContentType
+	loc := youtubePlayerRe.FindIndex(body)
+	if loc == nil {
+		return p
+	}
+	// The decoder stops at the end of the object, before the rest of the script.
+	_ = json.NewDecoder(bytes.NewReader(body[loc[1]:])).Decode(&p)
+	return p
+}
+
+// youtubeID returns the id of the video u links to, "" if it is not a
+// video link.
+func youtubeID(u *url.URL) string {
+	var id string
+	if matchesDomain(u.Hostname(), "youtu.be") {
+		id, _, _ = strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	} else if u.Path == "/watch" {
+		id = u.Query().Get("v")
+	} else {
+		for _, prefix := range youtubePathPrefixes {
+			if rest, ok := strings.CutPrefix(u.Path, prefix); ok {
+				id, _, _ = strings.Cut(rest, "/")
+				break
+			}
+		}
+	}
+	if !youtubeIDRe.MatchString(id) {
+		return ""
+	}
+	return id
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/val/autoga/internal"
 	"github.com/val/autoga/internal/scraper"
@@ -11,6 +14,13 @@ import (
 type scrapeHandler struct {
 	scraper           *scraper.Scraper
 	maxURLsPerRequest int
+	timeout           time.Duration // scraping budget per request; zero for none
+}
+
+// scrapeTimeout is the budget for scraping a batch under the server's
+// write timeout, leaving a tenth of it to encode and send the response.
+func scrapeTimeout(writeTimeout time.Duration) time.Duration {
+	return writeTimeout - writeTimeout/10
 }
 
 func (h *scrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -32,7 +42,46 @@ func (h *scrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results := h.scraper.Scrape(r.Context(), req.URLs)
+	if !req.Format.Valid() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "format must be one of text_flat, text, markdown, html",
+		})
+		return
+	}
+
+	if !req.Escape.Valid() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "escape must be one of sanitize, raw, json_string",
+		})
+		return
+	}
+
+	// URLs still unfinished at the deadline fail with a timeout instead of
+	// the whole response being cut off by the write timeout.
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	results, err := h.scraper.Scrape(ctx, req)
+	if r.Context().Err() != nil {
+		// The client has gone away; nobody reads the response.
+		return
+	}
+	if errors.Is(err, scraper.ErrBatchTooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "batch larger than the scrape queue"})
+		return
+	}
+	if errors.Is(err, scraper.ErrQueueFull) {
+		w.Header().Set("Retry-After", "5")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "server busy, retry later"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
 	writeJSON(w, http.StatusOK, internal.ScrapeResponse{Results: results})
 }
 
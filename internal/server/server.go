@@ -25,6 +25,7 @@ func New(cfg config.Config, sc *scraper.Scraper) *http.Server {
 	r.With(apiKeyAuth(cfg.APIKey)).Post("/scrape", (&scrapeHandler{
 		scraper:           sc,
 		maxURLsPerRequest: cfg.MaxURLsPerRequest,
+		timeout:           scrapeTimeout(cfg.WriteTimeout),
 	}).ServeHTTP)
 
 	return &http.Server{
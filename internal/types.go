@@ -1,19 +1,162 @@
 package internal
 
+// Format selects how ArticleResult.Content is rendered.
+type Format string
+
+const (
+	FormatTextFlat Format = "text_flat" // single line, all whitespace collapsed (default)
+	FormatText     Format = "text"      // plain text, paragraphs separated by blank lines
+	FormatMarkdown Format = "markdown"  // headings, lists, links and blockquotes kept
+	FormatHTML     Format = "html"      // sanitized article HTML
+)
+
+// Valid reports whether f is a known format. The empty format is valid and
+// means FormatTextFlat.
+func (f Format) Valid() bool {
+	switch f {
+	case "", FormatTextFlat, FormatText, FormatMarkdown, FormatHTML:
+		return true
+	}
+	return false
+}
+
+// Escape selects how text fields of ArticleResult are escaped.
+type Escape string
+
+const (
+	// EscapeSanitize replaces double-quotes with single-quotes and drops
+	// backslashes (default, kept for existing Make.com scenarios).
+	EscapeSanitize Escape = "sanitize"
+	// EscapeRaw returns text exactly as extracted.
+	EscapeRaw Escape = "raw"
+	// EscapeJSONString returns text JSON-escaped, ready to be pasted between
+	// the quotes of a JSON string literal.
+	EscapeJSONString Escape = "json_string"
+)
+
+// Valid reports whether e is a known escape mode. The empty mode is valid
+// and means EscapeSanitize.
+func (e Escape) Valid() bool {
+	switch e {
+	case "", EscapeSanitize, EscapeRaw, EscapeJSONString:
+		return true
+	}
+	return false
+}
+
 // ScrapeRequest is the incoming payload for POST /scrape.
 type ScrapeRequest struct {
 	URLs []string `json:"urls"`
+	// Format of the returned content; defaults to FormatTextFlat.
+	Format Format `json:"format"`
+	// Escape mode for text fields; defaults to EscapeSanitize.
+	Escape Escape `json:"escape"`
+	// NoCache bypasses cached results; fresh results still refresh the cache.
+	NoCache bool `json:"no_cache"`
+	// Snippets maps URLs, as they appear in URLs, to the summary the feed
+	// gave for them. A snippet is a last-resort candidate for the content.
+	Snippets map[string]string `json:"snippets"`
 }
 
+// ErrorCode classifies why a URL produced no usable article. Values are
+// stable so Make.com scenarios can route on them.
+type ErrorCode string
+
+const (
+	ErrTimeout         ErrorCode = "timeout"           // fetch or wait exceeded its deadline
+	ErrDNS             ErrorCode = "dns"               // host name did not resolve
+	ErrTLS             ErrorCode = "tls"               // TLS handshake or certificate failure
+	ErrNetwork         ErrorCode = "network"           // connection refused, reset or closed early
+	ErrHTTP4xx         ErrorCode = "http_4xx"          // client error status not covered below
+	ErrHTTP5xx         ErrorCode = "http_5xx"          // server error status
+	ErrTooLarge        ErrorCode = "too_large"         // body exceeds the size cap
+	ErrNotHTML         ErrorCode = "not_html"          // response is neither HTML nor a supported document type
+	ErrExtractionEmpty ErrorCode = "extraction_empty"  // page fetched but no article text found
+	ErrPaywall         ErrorCode = "paywall"           // content is behind a paywall
+	ErrBlocked         ErrorCode = "blocked"           // access denied or bot challenge
+	ErrForbiddenTarget ErrorCode = "forbidden_target"  // scheme or address refused by SSRF protection
+	ErrBlockedByRobots ErrorCode = "blocked_by_robots" // disallowed by the site's robots.txt
+	ErrSkipped         ErrorCode = "skipped"           // excluded by the domain policy, see SkipReason
+	ErrUnknown         ErrorCode = "unknown"           // anything else
+)
+
 // ArticleResult holds the extracted content for a single URL.
 type ArticleResult struct {
-	URL      string `json:"url"`
-	Title    string `json:"title"`
-	Byline   string `json:"byline"`
-	Content  string `json:"content"`
+	// URL is the address that was scraped: OriginalURL unwrapped and normalized.
+	URL string `json:"url"`
+	// OriginalURL is the URL exactly as it appeared in the request.
+	OriginalURL string `json:"original_url"`
+	Title       string `json:"title"`
+	Byline      string `json:"byline"`
+	Content     string `json:"content"`
+	// Format is the format Content is rendered in.
+	Format   Format `json:"format"`
 	Excerpt  string `json:"excerpt"`
 	SiteName string `json:"site_name"`
-	Error    string `json:"error"`
+	// PublishedAt and ModifiedAt are RFC 3339 timestamps, empty if unknown.
+	PublishedAt string `json:"published_at"`
+	ModifiedAt  string `json:"modified_at"`
+	// Language is the document language as a BCP 47 tag, e.g. "uk" or "en-US".
+	Language string `json:"language"`
+	// ImageURL is the lead image.
+	ImageURL string `json:"image_url"`
+	// FinalURL is the address the article was served from after redirects.
+	FinalURL string `json:"final_url"`
+	// RedirectChain lists every URL requested, oldest first; null without redirects.
+	RedirectChain []string `json:"redirect_chain"`
+	// CanonicalURL is the page's declared canonical address, falling back to
+	// FinalURL. It identifies the article for caching.
+	CanonicalURL string   `json:"canonical_url"`
+	Keywords     []string `json:"keywords"`
+	Section      string   `json:"section"`
+	// Extractor names the strategy whose content was chosen: site_rule,
+	// readability, json_ld, json_state, opengraph, rss_snippet or excerpt for
+	// HTML pages, pdf or plain_text for documents, and youtube, reddit or
+	// telegram for links read through the platform's own endpoints.
+	Extractor string `json:"extractor"`
+	// Confidence scores the chosen content from 0 to 1.
+	Confidence float64 `json:"confidence"`
+	// Wall is "paywalled" or "consent_wall" when Content looks like a paywall
+	// teaser or a cookie-consent dialog rather than the article; empty otherwise.
+	Wall string `json:"wall"`
+	// WallReason says which signal set Wall.
+	WallReason string `json:"wall_reason"`
+	// Variant names the version of the page the content came from when the
+	// page itself extracted poorly: amp, print or output_amp; empty for the page itself.
+	Variant string `json:"variant"`
+	// VariantURL is the address of that version, empty without a variant.
+	VariantURL string `json:"variant_url"`
+	// Pages is how many pages of a paginated article were merged into Content;
+	// 1 for a single page, 0 on failure.
+	Pages int `json:"pages"`
+	// Source is "archive" when Content came from an archived snapshot because
+	// the site blocked the request or paywalled the article; empty otherwise.
+	Source string `json:"source"`
+	// SnapshotURL is the address of that snapshot, empty unless Source is "archive".
+	SnapshotURL string `json:"snapshot_url"`
+	// ExtractionRule names the site rule applied during extraction, empty if none matched.
+	ExtractionRule string `json:"extraction_rule"`
+	// ContentType is the media type the content was extracted from, e.g.
+	// text/html or application/pdf.
+	ContentType string `json:"content_type"`
+	// Charset is the source encoding the page was decoded from before extraction.
+	Charset string `json:"charset"`
+	Error   string `json:"error"`
+	// ErrorCode classifies Error; empty on success.
+	ErrorCode ErrorCode `json:"error_code"`
+	// SkipReason says why the domain policy excluded the URL; empty unless ErrorCode is ErrSkipped.
+	SkipReason string `json:"skip_reason"`
+	// Retryable reports whether the same URL may succeed if requested again later.
+	Retryable bool `json:"retryable"`
+	// HTTPStatus is the status of the last HTTP response, zero if none was received.
+	HTTPStatus int `json:"http_status"`
+	// LimiterWaitMS is how long the fetch was held back by the per-host limiter
+	// and the site's robots.txt Crawl-delay.
+	LimiterWaitMS int64 `json:"limiter_wait_ms"`
+	// Attempts is the number of HTTP attempts made, including retries.
+	Attempts int `json:"attempts"`
+	// Cached is true when the result was served from the cache without fetching.
+	Cached bool `json:"cached"`
 }
 
 // ScrapeResponse is the outgoing payload for POST /scrape.